@@ -0,0 +1,501 @@
+// Package api provides an adapter for shipping slogdriver log entries through
+// the Cloud Logging API client instead of writing them to stdout for the
+// logging agent to pick up.
+//
+// To keep slogdriver free of the heavy client library dependency, the package
+// doesn't import cloud.google.com/go/logging directly. Instead, the entries are
+// handed to a Logger, which is trivial to implement on top of
+// (*logging.Logger).Log:
+//
+//	api.LoggerFunc(func(e api.Entry) {
+//		logger.Log(logging.Entry{
+//			Timestamp:    e.Timestamp,
+//			InsertID:     e.InsertID,
+//			Severity:     logging.Severity(e.Severity),
+//			Payload:      e.Payload,
+//			Labels:       e.Labels,
+//			Trace:        e.Trace,
+//			SpanID:       e.SpanID,
+//			TraceSampled: e.TraceSampled,
+//			SourceLocation: &logpb.LogEntrySourceLocation{
+//				File:     e.SourceLocation.File,
+//				Line:     e.SourceLocation.Line,
+//				Function: e.SourceLocation.Function,
+//			},
+//		})
+//	})
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+)
+
+// Entry is a log entry in the shape of logging.Entry. The Severity is the
+// LogSeverity enum value, e.g. 200 for INFO, which converts directly to
+// logging.Severity.
+//
+// The Payload contains the message and the attrs as written by the Handler:
+// strings, int64, uint64, float64, bool and nil values, and maps and slices of
+// them. The times are formatted as RFC 3339 strings, and the values encoded
+// with encoding/json are decoded back as by json.Unmarshal.
+//
+// The @type and serviceContext fields of the reported errors are kept in the
+// Payload, as Error Reporting reads them from the payload of the entries
+// written with the API. The attrs whose keys collide with the special fields
+// are kept in the Payload as well, unless they fill a special field that
+// hasn't been written yet and have the type of the field.
+type Entry struct {
+	Timestamp      time.Time
+	InsertID       string
+	Severity       int
+	Payload        map[string]any
+	Labels         map[string]string
+	Trace          string
+	SpanID         string
+	TraceSampled   bool
+	SourceLocation *SourceLocation
+	HTTPRequest    *HTTPRequest
+	Resource       *slogdriver.MonitoredResource
+}
+
+// SourceLocation is the source code location information of an Entry.
+type SourceLocation struct {
+	File     string
+	Line     int64
+	Function string
+}
+
+// HTTPRequest is the information about the HTTP request of an Entry, read
+// from the httpRequest group of the record, e.g. the one written by httplog.
+// The keys of the group are the JSON names of the fields of the
+// HttpRequest of the LogEntry. The sizes are either numbers or decimal strings,
+// and the latency is either a duration or a string like "1.500s".
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type HTTPRequest struct {
+	RequestMethod                  string
+	RequestURL                     string
+	RequestSize                    int64
+	Status                         int
+	ResponseSize                   int64
+	UserAgent                      string
+	RemoteIP                       string
+	ServerIP                       string
+	Referer                        string
+	Latency                        time.Duration
+	CacheLookup                    bool
+	CacheHit                       bool
+	CacheValidatedWithOriginServer bool
+	CacheFillBytes                 int64
+	Protocol                       string
+}
+
+// Logger receives the entries produced by the Handler.
+type Logger interface {
+	Log(e Entry)
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as a
+// Logger.
+type LoggerFunc func(e Entry)
+
+// Log implements Logger.
+func (fn LoggerFunc) Log(e Entry) {
+	fn(e)
+}
+
+// NewHandler returns a slogdriver.Handler that passes the log entries to the
+// Logger. The entries are built directly from the fields the Handler writes,
+// without encoding them as JSON.
+//
+// The options of the config that change the layout of the entries, i.e. the
+// keys, the severity format, the payload nesting, the label and source
+// styles and the encoder, are reset to the ones the entries are built from:
+// the defaults, except that the severity is written as its LogSeverity enum
+// value. The startup record is never written, as it isn't an entry.
+//
+// If the httpRequest group of a record isn't a valid HTTPRequest, the group
+// is kept in the Payload and the error is reported like the other errors of
+// the Handler, e.g. to Config.OnError.
+func NewHandler(logger Logger, config slogdriver.Config) *slogdriver.Handler {
+	config.MessageKey = ""
+	config.SeverityKey = ""
+	config.SeverityFormat = slogdriver.SeverityFormatEnum
+	config.EmitNumericSeverity = false
+	config.EmitReceiveTimestamp = false
+	config.NestPayload = false
+	config.PayloadKey = ""
+	config.LabelStyle = slogdriver.LabelStyleNested
+	config.SourceStyle = slogdriver.SourceStyleObject
+	config.EmitStartupRecord = false
+	config.NewEncoder = func(io.Writer) slogdriver.Encoder {
+		return &encoder{logger: logger}
+	}
+	return slogdriver.NewHandler(io.Discard, config)
+}
+
+type encoder struct {
+	logger Logger
+}
+
+// NewLine implements slogdriver.Encoder.
+func (enc *encoder) NewLine() slogdriver.LineWriter {
+	return &lineWriter{
+		logger: enc.logger,
+		entry:  Entry{Payload: map[string]any{}},
+		taken:  map[string]struct{}{},
+	}
+}
+
+// lineWriter builds an Entry from the fields of a line. The nested records
+// and lists are collected as maps and slices, and the top-level fields are
+// either set as the special fields of the Entry or added to the Payload when
+// they're done.
+type lineWriter struct {
+	logger Logger
+	entry  Entry
+	taken  map[string]struct{}
+	stack  []frame
+	err    error
+}
+
+type frame struct {
+	key    string
+	record map[string]any
+	list   []any
+	isList bool
+}
+
+// AddString implements slogdriver.LineWriter.
+func (w *lineWriter) AddString(key, value string) {
+	w.add(key, value)
+}
+
+// AddInt64 implements slogdriver.LineWriter.
+func (w *lineWriter) AddInt64(key string, value int64) {
+	w.add(key, value)
+}
+
+// AddUint64 implements slogdriver.LineWriter.
+func (w *lineWriter) AddUint64(key string, value uint64) {
+	w.add(key, value)
+}
+
+// AddBool implements slogdriver.LineWriter.
+func (w *lineWriter) AddBool(key string, value bool) {
+	w.add(key, value)
+}
+
+// AddFloat64 implements slogdriver.LineWriter.
+func (w *lineWriter) AddFloat64(key string, value float64) {
+	w.add(key, value)
+}
+
+// AddTime implements slogdriver.LineWriter.
+func (w *lineWriter) AddTime(key string, value time.Time) error {
+	w.add(key, value)
+	return nil
+}
+
+// AddMarshal implements slogdriver.LineWriter.
+func (w *lineWriter) AddMarshal(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	w.add(key, v)
+	return nil
+}
+
+// StartRecord implements slogdriver.LineWriter.
+func (w *lineWriter) StartRecord(key string) {
+	w.stack = append(w.stack, frame{key: key, record: map[string]any{}})
+}
+
+// EndRecord implements slogdriver.LineWriter.
+func (w *lineWriter) EndRecord() {
+	f := w.pop()
+	w.add(f.key, f.record)
+}
+
+// StartList implements slogdriver.LineWriter.
+func (w *lineWriter) StartList(key string) {
+	w.stack = append(w.stack, frame{key: key, list: []any{}, isList: true})
+}
+
+// EndList implements slogdriver.LineWriter.
+func (w *lineWriter) EndList() {
+	f := w.pop()
+	w.add(f.key, f.list)
+}
+
+// End implements slogdriver.LineWriter. The Entry is passed to the Logger
+// even if some of its fields were invalid, in which case the errors are
+// returned.
+func (w *lineWriter) End() error {
+	w.logger.Log(w.entry)
+	return w.err
+}
+
+func (w *lineWriter) pop() frame {
+	f := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	return f
+}
+
+func (w *lineWriter) add(key string, value any) {
+	if len(w.stack) == 0 {
+		w.addField(key, value)
+		return
+	}
+	f := &w.stack[len(w.stack)-1]
+	if f.isList {
+		f.list = append(f.list, payloadValue(value))
+		return
+	}
+	f.record[key] = payloadValue(value)
+}
+
+// addField sets the special field of the key if it hasn't been set yet and
+// the value has the type of the field, and adds the value to the Payload
+// otherwise.
+func (w *lineWriter) addField(key string, value any) {
+	if _, ok := w.taken[key]; !ok && w.setField(key, value) {
+		w.taken[key] = struct{}{}
+		return
+	}
+	w.entry.Payload[key] = payloadValue(value)
+}
+
+func (w *lineWriter) setField(key string, value any) bool {
+	e := &w.entry
+	switch key {
+	case slogdriver.FieldTimestamp:
+		return setValue(&e.Timestamp, value)
+	case slogdriver.FieldInsertID:
+		return setValue(&e.InsertID, value)
+	case slogdriver.FieldSeverity:
+		severity, ok := value.(uint64)
+		if ok {
+			e.Severity = int(severity)
+		}
+		return ok
+	case slogdriver.FieldLabels:
+		return setStringMap(&e.Labels, value)
+	case slogdriver.FieldTrace:
+		return setValue(&e.Trace, value)
+	case slogdriver.FieldSpanID:
+		return setValue(&e.SpanID, value)
+	case slogdriver.FieldTraceSampled:
+		return setValue(&e.TraceSampled, value)
+	case slogdriver.FieldSourceLocation:
+		return setSourceLocation(&e.SourceLocation, value)
+	case fieldHTTPRequest:
+		m, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		req, err := parseHTTPRequest(m)
+		if err != nil {
+			w.err = errors.Join(w.err, err)
+			return false
+		}
+		e.HTTPRequest = req
+		return true
+	case slogdriver.FieldResource:
+		return setResource(&e.Resource, value)
+	}
+	return false
+}
+
+// payloadValue returns the value in the form it's kept in the Payload.
+func payloadValue(value any) any {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+func setValue[T any](dst *T, value any) bool {
+	v, ok := value.(T)
+	if ok {
+		*dst = v
+	}
+	return ok
+}
+
+func setStringMap(dst *map[string]string, value any) bool {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		result[k] = s
+	}
+	*dst = result
+	return true
+}
+
+func setSourceLocation(dst **SourceLocation, value any) bool {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
+	var loc SourceLocation
+	for k, v := range m {
+		switch k {
+		case fieldSourceFile:
+			ok = setValue(&loc.File, v)
+		case fieldSourceLine:
+			ok = setValue(&loc.Line, v)
+		case fieldSourceFunction:
+			ok = setValue(&loc.Function, v)
+		}
+		if !ok {
+			return false
+		}
+	}
+	*dst = &loc
+	return true
+}
+
+func setResource(dst **slogdriver.MonitoredResource, value any) bool {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return false
+	}
+	var resource slogdriver.MonitoredResource
+	for k, v := range m {
+		switch k {
+		case fieldResourceType:
+			ok = setValue(&resource.Type, v)
+		case fieldResourceLabels:
+			ok = setStringMap(&resource.Labels, v)
+		}
+		if !ok {
+			return false
+		}
+	}
+	*dst = &resource
+	return true
+}
+
+func parseHTTPRequest(m map[string]any) (*HTTPRequest, error) {
+	var req HTTPRequest
+	for k, v := range m {
+		var err error
+		switch k {
+		case "requestMethod":
+			err = parseString(&req.RequestMethod, v)
+		case "requestUrl":
+			err = parseString(&req.RequestURL, v)
+		case "requestSize":
+			err = parseInt(&req.RequestSize, v)
+		case "status":
+			var status int64
+			err = parseInt(&status, v)
+			req.Status = int(status)
+		case "responseSize":
+			err = parseInt(&req.ResponseSize, v)
+		case "userAgent":
+			err = parseString(&req.UserAgent, v)
+		case "remoteIp":
+			err = parseString(&req.RemoteIP, v)
+		case "serverIp":
+			err = parseString(&req.ServerIP, v)
+		case "referer":
+			err = parseString(&req.Referer, v)
+		case "latency":
+			err = parseDuration(&req.Latency, v)
+		case "cacheLookup":
+			err = parseBool(&req.CacheLookup, v)
+		case "cacheHit":
+			err = parseBool(&req.CacheHit, v)
+		case "cacheValidatedWithOriginServer":
+			err = parseBool(&req.CacheValidatedWithOriginServer, v)
+		case "cacheFillBytes":
+			err = parseInt(&req.CacheFillBytes, v)
+		case "protocol":
+			err = parseString(&req.Protocol, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("api: invalid %s.%s: %w", fieldHTTPRequest, k, err)
+		}
+	}
+	return &req, nil
+}
+
+func parseString(dst *string, value any) error {
+	if !setValue(dst, value) {
+		return fmt.Errorf("expected a string, got %T", value)
+	}
+	return nil
+}
+
+func parseBool(dst *bool, value any) error {
+	if !setValue(dst, value) {
+		return fmt.Errorf("expected a bool, got %T", value)
+	}
+	return nil
+}
+
+func parseInt(dst *int64, value any) error {
+	switch v := value.(type) {
+	case int64:
+		*dst = v
+	case uint64:
+		*dst = int64(v)
+	case float64:
+		*dst = int64(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst = n
+	default:
+		return fmt.Errorf("expected an integer, got %T", value)
+	}
+	return nil
+}
+
+func parseDuration(dst *time.Duration, value any) error {
+	switch v := value.(type) {
+	case int64:
+		*dst = time.Duration(v)
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*dst = d
+	default:
+		return fmt.Errorf("expected a duration, got %T", value)
+	}
+	return nil
+}
+
+const (
+	fieldHTTPRequest    = "httpRequest"
+	fieldSourceFile     = "file"
+	fieldSourceLine     = "line"
+	fieldSourceFunction = "function"
+	fieldResourceType   = "type"
+	fieldResourceLabels = "labels"
+)
@@ -0,0 +1,307 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/api"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("special fields", func(t *testing.T) {
+		ctx := context.Background()
+		ctx = slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true}.Context(ctx)
+		ctx = slogdriver.AddLabels(ctx, slogdriver.NewLabel("foo", "bar"))
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{
+			ProjectID: "jectpro",
+		}))
+		now := time.Date(2023, 6, 15, 19, 24, 13, 123456789, time.UTC)
+		var pcs [1]uintptr
+		runtime.Callers(1, pcs[:])
+		r := slog.NewRecord(now, slog.LevelWarn, "hello", pcs[0])
+
+		err := logger.Handler().Handle(ctx, r)
+		entries := fake.Entries()
+		received := entries[0]
+
+		require.NoError(t, err)
+		require.NoError(t, errs.Err())
+		require.Equal(t, now, received.Timestamp)
+		require.Equal(t, 400, received.Severity)
+		require.Equal(t, map[string]string{"foo": "bar"}, received.Labels)
+		require.Equal(t, "projects/jectpro/traces/abc", received.Trace)
+		require.Equal(t, "def", received.SpanID)
+		require.Equal(t, true, received.TraceSampled)
+		require.Equal(t, true, received.SourceLocation != nil)
+	})
+
+	t.Run("severity", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			level    slog.Level
+			expected int
+		}{
+			{"debug", slogdriver.Config{}, slog.LevelDebug, 100},
+			{"info", slogdriver.Config{}, slog.LevelInfo, 200},
+			{"notice", slogdriver.Config{NoticeLevel: slog.LevelInfo + 2}, slog.LevelInfo + 2, 300},
+			{"warn", slogdriver.Config{}, slog.LevelWarn, 400},
+			{"error", slogdriver.Config{}, slog.LevelError, 500},
+			{"critical", slogdriver.Config{}, slogdriver.LevelCritical, 600},
+			{"string format", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, slog.LevelInfo, 200},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var fake FakeLogger
+				tt.config.Level = slog.LevelDebug
+				logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, tt.config))
+
+				logger.Log(ctx, tt.level, "severity")
+				entries := fake.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{}))
+		expected := map[string]any{
+			"message": "hello",
+			"str":     "abc",
+			"num":     int64(123),
+			"group": map[string]any{
+				"inner": true,
+			},
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "hello",
+			slog.String("str", "abc"),
+			slog.Int64("num", 123),
+			slog.Group("group", slog.Bool("inner", true)),
+		)
+		entries := fake.Entries()
+		received := entries[0].Payload
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("httpRequest", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{}))
+		expected := &api.HTTPRequest{
+			RequestMethod: "GET",
+			RequestURL:    "/foo",
+			Status:        200,
+			ResponseSize:  123,
+			Latency:       1500 * time.Millisecond,
+			CacheHit:      true,
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "request", slog.Group("httpRequest",
+			slog.String("requestMethod", "GET"),
+			slog.String("requestUrl", "/foo"),
+			slog.Int("status", 200),
+			slog.String("responseSize", "123"),
+			slog.String("latency", "1.500s"),
+			slog.Bool("cacheHit", true),
+		))
+		entries := fake.Entries()
+		received := entries[0]
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received.HTTPRequest)
+		require.Equal(t, map[string]any{"message": "request"}, received.Payload)
+	})
+
+	t.Run("invalid httpRequest", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{}))
+		expected := map[string]any{
+			"message":     "request",
+			"httpRequest": map[string]any{"requestMethod": "GET", "status": "OK"},
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "request", slog.Group("httpRequest",
+			slog.String("requestMethod", "GET"),
+			slog.String("status", "OK"),
+		))
+		entries := fake.Entries()
+		received := entries[0]
+		err := errs.Err()
+
+		require.Error(t, err)
+		require.Equal(t, 1, len(entries))
+		require.Equal(t, nil, received.HTTPRequest)
+		require.Equal(t, expected, received.Payload)
+	})
+
+	t.Run("colliding keys", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{}))
+		now := time.Date(2023, 6, 15, 19, 24, 13, 123456789, time.UTC)
+		r := slog.NewRecord(now, slog.LevelInfo, "hello", 0)
+		r.AddAttrs(
+			slog.String("timestamp", "yesterday"),
+			slog.Time("severity", now),
+			slog.Int("httpRequest", 123),
+			slog.Group("logging.googleapis.com/labels", slog.Int("foo", 1)),
+		)
+		expected := map[string]any{
+			"message":                       "hello",
+			"timestamp":                     "yesterday",
+			"severity":                      "2023-06-15T19:24:13.123456789Z",
+			"httpRequest":                   int64(123),
+			"logging.googleapis.com/labels": map[string]any{"foo": int64(1)},
+		}
+
+		err := logger.Handler().Handle(ctx, r)
+		entries := fake.Entries()
+		received := entries[0]
+
+		require.NoError(t, err)
+		require.NoError(t, errs.Err())
+		require.Equal(t, now, received.Timestamp)
+		require.Equal(t, 200, received.Severity)
+		require.Equal(t, nil, received.HTTPRequest)
+		require.Equal(t, nil, received.Labels)
+		require.Equal(t, expected, received.Payload)
+	})
+
+	t.Run("resource", func(t *testing.T) {
+		ctx := slogdriver.WithResource(context.Background(), slogdriver.MonitoredResource{
+			Type:   "pubsub_topic",
+			Labels: map[string]string{"topic_id": "events"},
+		})
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{}))
+		expected := &slogdriver.MonitoredResource{
+			Type:   "pubsub_topic",
+			Labels: map[string]string{"topic_id": "events"},
+		}
+
+		logger.InfoContext(ctx, "resource")
+		entries := fake.Entries()
+		received := entries[0]
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received.Resource)
+		require.Equal(t, map[string]any{"message": "resource"}, received.Payload)
+	})
+
+	t.Run("reset options", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			config slogdriver.Config
+		}{
+			{"MessageKey", slogdriver.Config{MessageKey: "msg"}},
+			{"SeverityKey", slogdriver.Config{SeverityKey: "level"}},
+			{"SeverityFormat", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}},
+			{"EmitNumericSeverity", slogdriver.Config{EmitNumericSeverity: true}},
+			{"EmitReceiveTimestamp", slogdriver.Config{EmitReceiveTimestamp: true}},
+			{"NestPayload", slogdriver.Config{NestPayload: true}},
+			{"PayloadKey", slogdriver.Config{PayloadKey: "data"}},
+			{"LabelStyle", slogdriver.Config{LabelStyle: slogdriver.LabelStylePrefixed}},
+			{"SourceStyle", slogdriver.Config{SourceStyle: slogdriver.SourceStyleString}},
+			{"RecordSeparator", slogdriver.Config{RecordSeparator: 0x1e}},
+			{"NewEncoder", slogdriver.Config{NewEncoder: func(io.Writer) slogdriver.Encoder { return nil }}},
+			{"EmitStartupRecord", slogdriver.Config{EmitStartupRecord: true}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := slogdriver.AddLabels(context.Background(), slogdriver.NewLabel("foo", "bar"))
+				var fake FakeLogger
+				logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, tt.config))
+				expected := map[string]any{"message": "hello", "str": "abc"}
+
+				logger.InfoContext(ctx, "hello", slog.String("str", "abc"))
+				entries := fake.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, 1, len(entries))
+				require.Equal(t, 200, entries[0].Severity)
+				require.Equal(t, map[string]string{"foo": "bar"}, entries[0].Labels)
+				require.Equal(t, true, entries[0].SourceLocation != nil)
+				require.Equal(t, expected, entries[0].Payload)
+			})
+		}
+	})
+
+	t.Run("insertId", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{
+			GenerateInsertID: true,
+		}))
+
+		logger.InfoContext(ctx, "first")
+		logger.InfoContext(ctx, "second")
+		entries := fake.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, true, entries[0].InsertID != "")
+		require.Equal(t, true, entries[0].InsertID < entries[1].InsertID)
+		require.Equal(t, map[string]any{"message": "first"}, entries[0].Payload)
+	})
+
+	t.Run("reported errors", func(t *testing.T) {
+		ctx := context.Background()
+		var fake FakeLogger
+		logger, errs := slogtest.NewWithErrorHandler(api.NewHandler(&fake, slogdriver.Config{
+			ReportErrors:   true,
+			ServiceContext: slogdriver.ServiceContext{Service: "svc", Version: "1.0"},
+		}))
+
+		logger.ErrorContext(ctx, "failed", slog.Any("error", errors.New("boom")))
+		entries := fake.Entries()
+		received := entries[0].Payload
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal[any](t, "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent", received[slogdriver.FieldType])
+		require.Equal[any](t, map[string]any{"service": "svc", "version": "1.0"}, received[slogdriver.FieldServiceContext])
+	})
+}
+
+type FakeLogger struct {
+	m       sync.Mutex
+	entries []api.Entry
+}
+
+func (l *FakeLogger) Log(e api.Entry) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.entries = append(l.entries, e)
+}
+
+func (l *FakeLogger) Entries() []api.Entry {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.entries
+}
@@ -0,0 +1,79 @@
+// Package baggagelabels provides a slog.Handler that mirrors context baggage,
+// such as OpenTelemetry baggage, into slogdriver labels.
+//
+// To avoid depending on a specific telemetry library, the baggage is read
+// through a Lookup. For OpenTelemetry, a Lookup can be written as:
+//
+//	func(ctx context.Context, key string) (string, bool) {
+//		m := baggage.FromContext(ctx).Member(key)
+//		return m.Value(), m.Key() != ""
+//	}
+package baggagelabels
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+)
+
+// Lookup returns the baggage value for the key from the context, and whether
+// the key was present.
+type Lookup func(ctx context.Context, key string) (string, bool)
+
+// Handler is a handler that adds baggage values as labels to the context
+// before passing the records to the inner handler.
+type Handler struct {
+	inner  slog.Handler
+	lookup Lookup
+	keys   []string
+}
+
+// NewHandler returns a new Handler. Only the baggage members with the given
+// keys are added as labels, to avoid leaking everything carried in baggage to
+// the logs.
+func NewHandler(inner slog.Handler, lookup Lookup, keys ...string) *Handler {
+	return &Handler{
+		inner:  inner,
+		lookup: lookup,
+		keys:   keys,
+	}
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var labels []slogdriver.Label
+	for _, key := range h.keys {
+		if value, ok := h.lookup(ctx, key); ok {
+			labels = append(labels, slogdriver.NewLabel(key, value))
+		}
+	}
+	if len(labels) != 0 {
+		ctx = slogdriver.AddLabels(ctx, labels...)
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(as)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// Unwrap returns the inner handler.
+func (h *Handler) Unwrap() slog.Handler {
+	return h.inner
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
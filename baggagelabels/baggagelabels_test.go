@@ -0,0 +1,80 @@
+package baggagelabels_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/baggagelabels"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestHandler(t *testing.T) {
+	type Entry struct {
+		Labels map[string]string `json:"logging.googleapis.com/labels"`
+		Group  struct {
+			Foo string
+		}
+	}
+
+	tests := []struct {
+		name     string
+		baggage  map[string]string
+		keys     []string
+		ctx      context.Context
+		expected map[string]string
+	}{
+		{
+			"no baggage",
+			nil,
+			[]string{"tenant"},
+			context.Background(),
+			nil,
+		},
+		{
+			"allowed keys only",
+			map[string]string{"tenant": "acme", "secret": "hunter2"},
+			[]string{"tenant", "request"},
+			context.Background(),
+			map[string]string{"tenant": "acme"},
+		},
+		{
+			"merged with context labels",
+			map[string]string{"tenant": "acme"},
+			[]string{"tenant"},
+			slogdriver.AddLabels(context.Background(), slogdriver.NewLabel("service", "api")),
+			map[string]string{"service": "api", "tenant": "acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := withBaggage(tt.ctx, tt.baggage)
+			var capture slogtest.Capture[Entry]
+			h := baggagelabels.NewHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}), lookup, tt.keys...)
+			logger, errs := slogtest.NewWithErrorHandler(h)
+
+			logger.WithGroup("Group").With("Foo", "bar").InfoContext(ctx, "baggage")
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, received.Labels)
+			require.Equal(t, "bar", received.Group.Foo)
+		})
+	}
+}
+
+type baggageKeyT struct{}
+
+func withBaggage(ctx context.Context, baggage map[string]string) context.Context {
+	return context.WithValue(ctx, baggageKeyT{}, baggage)
+}
+
+func lookup(ctx context.Context, key string) (string, bool) {
+	baggage, _ := ctx.Value(baggageKeyT{}).(map[string]string)
+	v, ok := baggage[key]
+	return v, ok
+}
@@ -0,0 +1,22 @@
+package slogdriver
+
+import "runtime/debug"
+
+// buildRevision returns the VCS revision the binary was built from, or an
+// empty string if the build info isn't available.
+func buildRevision() string {
+	info, ok := readBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == labelBuildRevision {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+var readBuildInfo = debug.ReadBuildInfo
+
+const labelBuildRevision = "vcs.revision"
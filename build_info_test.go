@@ -0,0 +1,71 @@
+package slogdriver
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestIncludeBuildRevision(t *testing.T) {
+	type Entry struct {
+		Labels map[string]string `json:"logging.googleapis.com/labels"`
+	}
+
+	tests := []struct {
+		name     string
+		config   Config
+		info     *debug.BuildInfo
+		ok       bool
+		expected map[string]string
+	}{
+		{
+			"disabled",
+			Config{},
+			&debug.BuildInfo{Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}}},
+			true,
+			nil,
+		},
+		{
+			"enabled",
+			Config{IncludeBuildRevision: true},
+			&debug.BuildInfo{Settings: []debug.BuildSetting{{Key: "vcs", Value: "git"}, {Key: "vcs.revision", Value: "abc123"}}},
+			true,
+			map[string]string{"vcs.revision": "abc123"},
+		},
+		{
+			"no revision",
+			Config{IncludeBuildRevision: true},
+			&debug.BuildInfo{},
+			true,
+			nil,
+		},
+		{
+			"no build info",
+			Config{IncludeBuildRevision: true},
+			nil,
+			false,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(read func() (*debug.BuildInfo, bool)) { readBuildInfo = read }(readBuildInfo)
+			readBuildInfo = func() (*debug.BuildInfo, bool) { return tt.info, tt.ok }
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(NewHandler(&capture, tt.config))
+
+			logger.InfoContext(ctx, "revision")
+			entries := capture.Entries()
+			received := entries[0].Labels
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package slogdriver
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+)
+
+// DedupMode defines how attrs with duplicate keys on the same level are
+// written.
+type DedupMode int
+
+const (
+	// DedupNone writes all the attrs, even if the keys are duplicated.
+	DedupNone DedupMode = iota
+	// DedupLastWins writes the value of the last attr with the key, in the
+	// position of the first one.
+	DedupLastWins
+	// DedupFirstWins writes the value of the first attr with the key.
+	DedupFirstWins
+	// DedupRename writes all the attrs, suffixing the keys of the duplicates
+	// with their ordinal, e.g. "key", "key#2", "key#3".
+	DedupRename
+)
+
+// defersAttrs reports whether the attrs added with WithAttrs are kept in
+// frames until Handle instead of being encoded ahead of time, as required by
+// Config.DedupAttrs, Config.SortKeys and Config.NewEncoder.
+func (h *Handler) defersAttrs() bool {
+	return h.config.DedupAttrs != DedupNone || h.config.SortKeys || h.customEncoder != nil
+}
+
+// attrFrame contains the attrs added with WithAttrs to a group opened with
+// WithGroup, when the attrs can't be encoded ahead of time due to
+// Config.DedupAttrs, Config.SortKeys or Config.NewEncoder.
+type attrFrame struct {
+	name  string
+	attrs []slog.Attr
+}
+
+func (h *Handler) withAttrsDedup(as []slog.Attr) slog.Handler {
+	clone := *h
+	as, values := h.resolveAttrSlice(as)
+	clone.addValues(values)
+	for _, attr := range as {
+		clone.hasErrorAttr = clone.hasErrorAttr || isErrorAttr(attr)
+	}
+	clone.frames = slices.Clone(h.frames)
+	if len(clone.frames) == 0 {
+		clone.frames = append(clone.frames, attrFrame{})
+	}
+	frame := &clone.frames[len(clone.frames)-1]
+	frame.attrs = append(slices.Clip(frame.attrs), as...)
+	return &clone
+}
+
+func (h *Handler) withGroupDedup(name string) slog.Handler {
+	clone := *h
+	clone.frames = slices.Clone(h.frames)
+	if len(clone.frames) == 0 {
+		clone.frames = append(clone.frames, attrFrame{})
+	}
+	clone.frames = append(clone.frames, attrFrame{name: name})
+	clone.inGroup = true
+	return &clone
+}
+
+func (h *Handler) addAttrsDedup(ctx context.Context, l LineWriter, r *slog.Record) error {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	h.recordAttrs(r, func(attr slog.Attr) {
+		attrs = append(attrs, attr)
+	})
+	for i := len(h.frames) - 1; i >= 0; i-- {
+		attrs = append(slices.Clip(h.frames[i].attrs), attrs...)
+		if i != 0 {
+			attrs = []slog.Attr{{Key: h.frames[i].name, Value: slog.GroupValue(attrs...)}}
+		}
+	}
+	attrs = h.dedupAttrs(attrs)
+	truncated := h.truncatedAttrs(r)
+
+	endPayload := h.startPayload(l, len(attrs) == 0 && truncated == 0)
+	defer endPayload()
+	defer addTruncated(l, truncated)
+
+	var err error
+	for _, attr := range attrs {
+		err = errors.Join(err, h.addAttr(l, "", nil, attr))
+	}
+	return err
+}
+
+// dedupAttrs returns the non-empty attrs with the duplicate keys resolved
+// according to Config.DedupAttrs, and sorted by key with Config.SortKeys,
+// inlining the groups with empty keys and recursing into the other groups.
+func (h *Handler) dedupAttrs(attrs []slog.Attr) []slog.Attr {
+	result := make([]slog.Attr, 0, len(attrs))
+	indices := make(map[string]int, len(attrs))
+	counts := make(map[string]int)
+	var add func(attrs []slog.Attr)
+	add = func(attrs []slog.Attr) {
+		for _, attr := range attrs {
+			attr.Value = h.resolve(attr.Value)
+			if h.isOmittedAttr(attr) {
+				continue
+			}
+			if attr.Value.Kind() == slog.KindGroup {
+				if attr.Key == "" {
+					add(attr.Value.Group())
+					continue
+				}
+				attr.Value = slog.GroupValue(h.dedupAttrs(attr.Value.Group())...)
+			}
+
+			key := h.attrKey(attr.Key)
+			i, ok := indices[key]
+			switch {
+			case !ok || h.config.DedupAttrs == DedupNone:
+				indices[key] = len(result)
+				counts[key] = 1
+				result = append(result, attr)
+			case h.config.DedupAttrs == DedupLastWins:
+				result[i] = attr
+			case h.config.DedupAttrs == DedupRename:
+				counts[key]++
+				attr.Key += "#" + strconv.Itoa(counts[key])
+				result = append(result, attr)
+			}
+		}
+	}
+	add(attrs)
+	if h.config.SortKeys {
+		slices.SortStableFunc(result, func(a, b slog.Attr) int {
+			return cmp.Compare(h.attrKey(a.Key), h.attrKey(b.Key))
+		})
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package slogdriver
+
+import (
+	"context"
+	"log/slog"
+)
+
+// DiscardHandler is a handler that discards all the log entries.
+type DiscardHandler struct{}
+
+// NewDiscardHandler returns a new DiscardHandler.
+//
+// As Enabled always returns false, slog.Logger skips building the records
+// altogether, making logging through it effectively free.
+func NewDiscardHandler() DiscardHandler {
+	return DiscardHandler{}
+}
+
+// Handle implements slog.Handler.
+func (h DiscardHandler) Handle(ctx context.Context, r slog.Record) error {
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h DiscardHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup implements slog.Handler.
+func (h DiscardHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// Enabled implements slog.Handler.
+func (h DiscardHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return false
+}
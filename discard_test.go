@@ -0,0 +1,31 @@
+package slogdriver_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+func TestDiscardHandler(t *testing.T) {
+	ctx := context.Background()
+	var h slog.Handler = slogdriver.NewDiscardHandler()
+
+	require.Equal(t, false, h.Enabled(ctx, slog.Level(1e6)))
+	require.Equal(t, h, h.WithAttrs([]slog.Attr{slog.String("foo", "bar")}))
+	require.Equal(t, h, h.WithGroup("group"))
+	require.NoError(t, h.Handle(ctx, slog.NewRecord(time.Time{}, slog.LevelError, "discard", 0)))
+}
+
+func BenchmarkDiscardHandler(b *testing.B) {
+	ctx := context.Background()
+	logger := slog.New(slogdriver.NewDiscardHandler()).With(slog.String("foo", "bar"))
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		logger.LogAttrs(ctx, slog.LevelError, "hello world", slog.Int("n", n))
+	}
+}
@@ -0,0 +1,14 @@
+package slogdriver
+
+// DurationFormat defines how time.Duration attr values are encoded.
+type DurationFormat int
+
+const (
+	// DurationFormatNanos encodes the durations as the number of
+	// nanoseconds, e.g. 123456.
+	DurationFormatNanos DurationFormat = iota
+	// DurationFormatObject encodes the durations as objects with both the
+	// number of nanoseconds and the human readable form returned by
+	// time.Duration.String, e.g. {"nanos":123456,"human":"123.456µs"}.
+	DurationFormatObject
+)
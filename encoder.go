@@ -0,0 +1,37 @@
+package slogdriver
+
+import (
+	"io"
+	"time"
+)
+
+// Encoder encodes the log entries as lines of JSON, allowing the use of other
+// JSON encoders than the default goldjson.Encoder via Config.NewEncoder.
+type Encoder interface {
+	// NewLine returns a LineWriter for a new entry.
+	NewLine() LineWriter
+}
+
+// LineWriter writes the fields of a single entry as a JSON object. The keys
+// are ignored inside lists. It's implemented by *goldjson.LineWriter.
+type LineWriter interface {
+	AddString(key, value string)
+	AddInt64(key string, value int64)
+	AddUint64(key string, value uint64)
+	AddBool(key string, value bool)
+	AddFloat64(key string, value float64)
+	AddTime(key string, value time.Time) error
+	AddMarshal(key string, value any) error
+	StartRecord(key string)
+	EndRecord()
+	StartList(key string)
+	EndList()
+
+	// End closes the object and writes it as a line to the io.Writer of the
+	// Encoder, returning any error from the write.
+	End() error
+}
+
+// NewEncoderFunc returns an Encoder that writes the lines to w. The writes to
+// w are serialized and retried until the whole line has been written.
+type NewEncoderFunc func(w io.Writer) Encoder
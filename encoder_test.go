@@ -0,0 +1,136 @@
+package slogdriver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestNewEncoder(t *testing.T) {
+	ctx := context.Background()
+	var capture slogtest.Capture[map[string]any]
+	encoders := 0
+	var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+		OmitTimestamp:  true,
+		SeverityFormat: slogdriver.SeverityFormatString,
+		NewEncoder: func(w io.Writer) slogdriver.Encoder {
+			encoders++
+			return &MapEncoder{w: w}
+		},
+	})
+	h = h.WithAttrs([]slog.Attr{slog.String("prepared", "1")})
+	h = h.WithGroup("Group")
+	logger, errs := slogtest.NewWithErrorHandler(h)
+	expected := map[string]any{
+		"message":  "custom",
+		"severity": "INFO",
+		"prepared": "1",
+		"Group": map[string]any{
+			"str":  "abc",
+			"num":  float64(-1),
+			"uint": float64(2),
+			"bool": true,
+			"list": []any{"a", float64(1)},
+			"any":  map[string]any{"foo": "bar"},
+		},
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "custom",
+		slog.String("str", "abc"),
+		slog.Int("num", -1),
+		slog.Uint64("uint", 2),
+		slog.Bool("bool", true),
+		slog.Any("list", []any{"a", 1}),
+		slog.Any("any", map[string]string{"foo": "bar"}),
+	)
+	entries := capture.Entries()
+	received := entries[0]
+	delete(received, "logging.googleapis.com/sourceLocation")
+	err := errs.Err()
+
+	require.NoError(t, err)
+	require.Equal(t, 1, encoders)
+	require.Equal(t, expected, received)
+}
+
+// MapEncoder is a trivial Encoder that builds the entries in memory and
+// marshals them with encoding/json.
+type MapEncoder struct {
+	w io.Writer
+}
+
+func (e *MapEncoder) NewLine() slogdriver.LineWriter {
+	return &MapLineWriter{w: e.w, stack: []any{map[string]any{}}}
+}
+
+type MapLineWriter struct {
+	w     io.Writer
+	stack []any
+	keys  []string
+}
+
+func (l *MapLineWriter) add(key string, value any) {
+	switch top := l.stack[len(l.stack)-1].(type) {
+	case map[string]any:
+		top[key] = value
+	case []any:
+		l.stack[len(l.stack)-1] = append(top, value)
+	}
+}
+
+func (l *MapLineWriter) AddString(key, value string)        { l.add(key, value) }
+func (l *MapLineWriter) AddInt64(key string, value int64)   { l.add(key, value) }
+func (l *MapLineWriter) AddUint64(key string, value uint64) { l.add(key, value) }
+func (l *MapLineWriter) AddBool(key string, value bool)     { l.add(key, value) }
+func (l *MapLineWriter) AddFloat64(key string, value float64) {
+	l.add(key, value)
+}
+
+func (l *MapLineWriter) AddTime(key string, value time.Time) error {
+	l.add(key, value)
+	return nil
+}
+
+func (l *MapLineWriter) AddMarshal(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	l.add(key, json.RawMessage(data))
+	return nil
+}
+
+func (l *MapLineWriter) StartRecord(key string) {
+	l.keys = append(l.keys, key)
+	l.stack = append(l.stack, map[string]any{})
+}
+
+func (l *MapLineWriter) StartList(key string) {
+	l.keys = append(l.keys, key)
+	l.stack = append(l.stack, []any{})
+}
+
+func (l *MapLineWriter) EndRecord() { l.end() }
+func (l *MapLineWriter) EndList()   { l.end() }
+
+func (l *MapLineWriter) end() {
+	value, key := l.stack[len(l.stack)-1], l.keys[len(l.keys)-1]
+	l.stack, l.keys = l.stack[:len(l.stack)-1], l.keys[:len(l.keys)-1]
+	l.add(key, value)
+}
+
+func (l *MapLineWriter) End() error {
+	data, err := json.Marshal(l.stack[0])
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(data, '\n'))
+	return err
+}
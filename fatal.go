@@ -0,0 +1,49 @@
+package slogdriver
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Fatal logs the message and the attrs at LevelCritical, closes the handler
+// of the logger if it has a Close method, such as Handler, or flushes it if it
+// has a Flush method, and then exits the process with status 1. The wrapping
+// handlers, such as Sampler and FilterHandler, are unwrapped with their Unwrap
+// methods to reach the one to close.
+func Fatal(ctx context.Context, logger *slog.Logger, msg string, attrs ...slog.Attr) {
+	h := logger.Handler()
+	if h.Enabled(ctx, LevelCritical) {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		r := slog.NewRecord(time.Now(), LevelCritical, msg, pcs[0])
+		r.AddAttrs(attrs...)
+		_ = h.Handle(ctx, r)
+	}
+	closeHandler(h)
+	osExit(1)
+}
+
+// closeHandler closes or flushes the first handler in the chain of wrapped
+// handlers that has a Close or a Flush method.
+func closeHandler(h slog.Handler) {
+	for {
+		switch h := h.(type) {
+		case interface{ Close() error }:
+			_ = h.Close()
+			return
+		case interface{ Flush() error }:
+			_ = h.Flush()
+			return
+		}
+		u, ok := h.(interface{ Unwrap() slog.Handler })
+		if !ok {
+			return
+		}
+		h = u.Unwrap()
+	}
+}
+
+var osExit = os.Exit
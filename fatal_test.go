@@ -0,0 +1,97 @@
+package slogdriver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+func TestFatal(t *testing.T) {
+	type SourceLocation struct {
+		Function string `json:"function"`
+	}
+
+	type Entry struct {
+		Message        string         `json:"message"`
+		Severity       string         `json:"severity"`
+		Foo            string         `json:"foo"`
+		SourceLocation SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+	}
+
+	ctx := context.Background()
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	logger := slog.New(NewHandler(w, Config{SeverityFormat: SeverityFormatString}))
+	exitCode := 0
+	flushed := false
+	defer func(exit func(int)) { osExit = exit }(osExit)
+	osExit = func(code int) {
+		exitCode = code
+		flushed = out.Len() != 0
+	}
+	expected := Entry{
+		Message:        "fatal",
+		Severity:       "CRITICAL",
+		Foo:            "bar",
+		SourceLocation: SourceLocation{"github.com/jussi-kalliokoski/slogdriver.TestFatal"},
+	}
+
+	Fatal(ctx, logger, "fatal", slog.String("foo", "bar"))
+	var received Entry
+	err := json.Unmarshal([]byte(out.String()), &received)
+
+	require.NoError(t, err)
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, true, flushed)
+	require.Equal(t, expected, received)
+}
+
+func TestFatalWrapped(t *testing.T) {
+	type Entry struct {
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+	}
+
+	ctx := context.Background()
+	var out strings.Builder
+	w := &SyncWriter{Writer: bufio.NewWriter(&out)}
+	inner := NewHandler(w, Config{SeverityFormat: SeverityFormatString})
+	logger := slog.New(Filter(NewSampler(inner, SamplerConfig{Rate: 1}), func(ctx context.Context, r slog.Record) bool {
+		return true
+	}))
+	exitCode := 0
+	flushed := false
+	synced := false
+	defer func(exit func(int)) { osExit = exit }(osExit)
+	osExit = func(code int) {
+		exitCode = code
+		flushed = out.Len() != 0
+		synced = w.synced
+	}
+	expected := Entry{Message: "fatal", Severity: "CRITICAL"}
+
+	Fatal(ctx, logger, "fatal")
+	var received Entry
+	err := json.Unmarshal([]byte(out.String()), &received)
+
+	require.NoError(t, err)
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, true, flushed)
+	require.Equal(t, true, synced)
+	require.Equal(t, expected, received)
+}
+
+type SyncWriter struct {
+	*bufio.Writer
+	synced bool
+}
+
+func (w *SyncWriter) Sync() error {
+	w.synced = true
+	return nil
+}
@@ -0,0 +1,32 @@
+package slogdriver
+
+// The keys of the special fields written by the Handler.
+//
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+const (
+	FieldMessage        = "message"
+	FieldTimestamp      = "timestamp"
+	FieldSeverity       = "severity"
+	FieldSourceLocation = "logging.googleapis.com/sourceLocation"
+	FieldTrace          = "logging.googleapis.com/trace"
+	FieldSpanID         = "logging.googleapis.com/spanId"
+	FieldTraceSampled   = "logging.googleapis.com/trace_sampled"
+	FieldLabels         = "logging.googleapis.com/labels"
+	FieldInsertID       = "logging.googleapis.com/insertId"
+)
+
+// The keys of the fields written by the Handler that are ignored by the logging
+// agent, but can be used when shipping the entries with the Cloud Logging API.
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+const (
+	FieldResource = "resource"
+)
+
+// The keys of the fields written by the Handler for Error Reporting.
+//
+// See https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const (
+	FieldType           = "@type"
+	FieldServiceContext = "serviceContext"
+)
@@ -0,0 +1,35 @@
+package slogdriver_test
+
+import (
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		expected string
+	}{
+		{"FieldMessage", slogdriver.FieldMessage, "message"},
+		{"FieldTimestamp", slogdriver.FieldTimestamp, "timestamp"},
+		{"FieldSeverity", slogdriver.FieldSeverity, "severity"},
+		{"FieldSourceLocation", slogdriver.FieldSourceLocation, "logging.googleapis.com/sourceLocation"},
+		{"FieldTrace", slogdriver.FieldTrace, "logging.googleapis.com/trace"},
+		{"FieldSpanID", slogdriver.FieldSpanID, "logging.googleapis.com/spanId"},
+		{"FieldTraceSampled", slogdriver.FieldTraceSampled, "logging.googleapis.com/trace_sampled"},
+		{"FieldLabels", slogdriver.FieldLabels, "logging.googleapis.com/labels"},
+		{"FieldInsertID", slogdriver.FieldInsertID, "logging.googleapis.com/insertId"},
+		{"FieldResource", slogdriver.FieldResource, "resource"},
+		{"FieldType", slogdriver.FieldType, "@type"},
+		{"FieldServiceContext", slogdriver.FieldServiceContext, "serviceContext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.field)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package slogdriver
+
+import (
+	"context"
+	"log/slog"
+)
+
+// FilterHandler is a handler that passes only the records accepted by a
+// predicate to the inner handler, e.g. to suppress the entries of noisy
+// health checks.
+type FilterHandler struct {
+	inner slog.Handler
+	keep  func(ctx context.Context, r slog.Record) bool
+}
+
+// Filter returns a new FilterHandler that passes the records for which keep
+// returns true to the inner handler and drops the rest.
+//
+// The records passed to keep don't include the attrs added with WithAttrs.
+func Filter(inner slog.Handler, keep func(ctx context.Context, r slog.Record) bool) *FilterHandler {
+	return &FilterHandler{inner: inner, keep: keep}
+}
+
+// Handle implements slog.Handler.
+func (h *FilterHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.keep(ctx, r) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *FilterHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(as)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *FilterHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// Unwrap returns the inner handler.
+func (h *FilterHandler) Unwrap() slog.Handler {
+	return h.inner
+}
+
+// Enabled implements slog.Handler.
+func (h *FilterHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
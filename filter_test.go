@@ -0,0 +1,45 @@
+package slogdriver_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestFilter(t *testing.T) {
+	type Group struct {
+		Foo string
+		Bar string
+	}
+
+	type Entry struct {
+		Message string `json:"message"`
+		Group   Group
+	}
+
+	ctx := context.Background()
+	var capture slogtest.Capture[Entry]
+	h := slogdriver.Filter(slogdriver.NewHandler(&capture, slogdriver.Config{}), func(ctx context.Context, r slog.Record) bool {
+		return r.Message != "healthz"
+	})
+	logger, errs := slogtest.NewWithErrorHandler(h)
+	logger = logger.WithGroup("Group").With(slog.String("Foo", "foo"))
+	expected := []Entry{
+		{"request", Group{"foo", "bar"}},
+		{"healthz failed", Group{"foo", "bar"}},
+	}
+
+	logger.InfoContext(ctx, "healthz", slog.String("Bar", "bar"))
+	logger.InfoContext(ctx, "request", slog.String("Bar", "bar"))
+	logger.InfoContext(ctx, "healthz")
+	logger.ErrorContext(ctx, "healthz failed", slog.String("Bar", "bar"))
+	received := capture.Entries()
+	err := errs.Err()
+
+	require.NoError(t, err)
+	require.Equal(t, expected, received)
+}
@@ -2,4 +2,4 @@ module github.com/jussi-kalliokoski/slogdriver
 
 go 1.21
 
-require github.com/jussi-kalliokoski/goldjson v1.0.0 // indirect
+require github.com/jussi-kalliokoski/goldjson v1.0.0
@@ -0,0 +1,23 @@
+package slogdriver
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// goroutineID returns the ID of the current goroutine, parsed from the
+// "goroutine N [status]:" header of its stack trace.
+func goroutineID() uint64 {
+	// enough for the header, the rest of the trace is truncated
+	buf := make([]byte, 64)
+	b := buf[:runtime.Stack(buf, false)]
+	b = bytes.TrimPrefix(b, goroutinePrefix)
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+var goroutinePrefix = []byte("goroutine ")
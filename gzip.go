@@ -0,0 +1,42 @@
+package slogdriver
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// NewGzipWriter returns an io.WriteCloser that compresses the data written to
+// it with gzip before writing it to w, e.g. for saving disk space with file
+// sinks.
+//
+// Every write is flushed to w, so the entries written by a Handler can be
+// decompressed line by line even from a partially written stream. Close
+// writes the gzip footer, finalizing the stream, but doesn't close w.
+func NewGzipWriter(w io.Writer) io.WriteCloser {
+	return &gzipWriter{zw: gzip.NewWriter(w)}
+}
+
+type gzipWriter struct {
+	m  sync.Mutex
+	zw *gzip.Writer
+}
+
+// Write implements io.Writer.
+func (w *gzipWriter) Write(data []byte) (n int, err error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if n, err = w.zw.Write(data); err != nil {
+		return n, err
+	}
+	return n, w.zw.Flush()
+}
+
+// Close implements io.Closer.
+func (w *gzipWriter) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return w.zw.Close()
+}
@@ -0,0 +1,68 @@
+package slogdriver_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestGzipWriter(t *testing.T) {
+	type Entry struct {
+		Message string `json:"message"`
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+		w := slogdriver.NewGzipWriter(&buf)
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(w, slogdriver.Config{}))
+		expected := []Entry{{"first"}, {"second"}}
+
+		logger.InfoContext(ctx, "first")
+		logger.InfoContext(ctx, "second")
+		closeErr := w.Close()
+		var capture slogtest.Capture[Entry]
+		r, readErr := gzip.NewReader(&buf)
+		require.NoError(t, readErr)
+		_, copyErr := io.Copy(&capture, r)
+		received := capture.Entries()
+
+		require.NoError(t, errs.Err())
+		require.NoError(t, closeErr)
+		require.NoError(t, copyErr)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("partial stream", func(t *testing.T) {
+		ctx := context.Background()
+		var buf bytes.Buffer
+		w := slogdriver.NewGzipWriter(&buf)
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(w, slogdriver.Config{}))
+		expected := []Entry{{"first"}, {"second"}}
+
+		logger.InfoContext(ctx, "first")
+		logger.InfoContext(ctx, "second")
+		r, readErr := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, readErr)
+		lines := bufio.NewReader(r)
+		var received []Entry
+		for range expected {
+			line, err := lines.ReadBytes('\n')
+			require.NoError(t, err)
+			var entry Entry
+			require.NoError(t, json.Unmarshal(line, &entry))
+			received = append(received, entry)
+		}
+
+		require.NoError(t, errs.Err())
+		require.Equal(t, expected, received)
+	})
+}
@@ -1,95 +1,646 @@
 package slogdriver
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jussi-kalliokoski/goldjson"
+	"github.com/jussi-kalliokoski/goldjson/tokens"
 )
 
 // Config defines the Stackdriver configuration.
 type Config struct {
+	// ProjectID is used for turning bare trace IDs into the resource names
+	// of the traces. If it's empty, the trace fields are left out for bare
+	// trace IDs.
 	ProjectID string
 	Level     slog.Leveler
+
+	// ReportErrors marks the entries with a level of slog.LevelError or
+	// above to be picked up by Error Reporting.
+	//
+	// See https://cloud.google.com/error-reporting/docs/formatting-error-messages
+	ReportErrors bool
+
+	// ServiceContext identifies the service in Error Reporting. Only used
+	// when ReportErrors is enabled.
+	ServiceContext ServiceContext
+
+	// ReportStackTraces adds a "stack_trace" field to the entries marked by
+	// ReportErrors, for grouping the errors in Error Reporting. The stack
+	// trace is taken from the first error attr of the record with a
+	// StackTrace method returning a slice of program counters, such as the
+	// errors of github.com/pkg/errors, and from the goroutine logging the
+	// entry otherwise.
+	ReportStackTraces bool
+
+	// OmitTimestamp leaves out the timestamp field, leaving it to the
+	// platform to stamp the entries with the time of ingestion.
+	OmitTimestamp bool
+
+	// TimestampUTC converts the timestamp of the record to UTC, for tooling
+	// that mishandles the zone offsets of RFC 3339 timestamps.
+	TimestampUTC bool
+
+	// MessageKey overrides the key of the message field. Defaults to
+	// "message".
+	MessageKey string
+
+	// SeverityKey overrides the key of the severity field. Defaults to
+	// "severity".
+	SeverityKey string
+
+	// SeverityFormat defines how the severity is encoded. Defaults to
+	// SeverityFormatNumeric.
+	SeverityFormat SeverityFormat
+
+	// LowercaseSeverity writes the severity names in lowercase, e.g. "error",
+	// which the logging agent recognizes as well. Only applies with
+	// SeverityFormatString.
+	LowercaseSeverity bool
+
+	// OnError, if set, is called with the errors encountered when handling a
+	// record, e.g. errors from the underlying writer or from marshaling
+	// attribute values. The error is still returned from Handle as well.
+	OnError func(error)
+
+	// OmitUnsampledFlag leaves out the trace_sampled field when the trace is
+	// not sampled, instead of writing it as false.
+	OmitUnsampledFlag bool
+
+	// GenerateInsertID adds a process-unique, monotonically increasing
+	// insertId to the entries, so that entries with identical timestamps
+	// retain their order.
+	//
+	// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#FIELDS.insert_id
+	GenerateInsertID bool
+
+	// KeyTransform, if set, is applied to the keys of the attributes and the
+	// names of the groups, e.g. SnakeCase.
+	KeyTransform func(string) string
+
+	// NestPayload places the attributes under a jsonPayload object instead
+	// of the root of the entry. The special fields, such as severity and
+	// trace, remain at the root.
+	NestPayload bool
+
+	// PayloadKey, if set, places the attributes under an object with the key
+	// instead of the root of the entry, as with NestPayload. Defaults to
+	// "jsonPayload" with NestPayload, and to the root of the entry otherwise.
+	PayloadKey string
+
+	// EscalateOnError raises the severity of entries to at least ERROR when
+	// they have an attribute with an error value, or with an "error" or "err"
+	// key. The level of the record still decides whether it is logged.
+	EscalateOnError bool
+
+	// TimeAttrFormat, if set, is the layout used for formatting time.Time
+	// attribute values, e.g. "2006-01-02T15:04:05.000Z07:00" for millisecond
+	// precision. Defaults to time.RFC3339Nano.
+	TimeAttrFormat string
+
+	// TimeAttrUTC converts time.Time attribute values to UTC before
+	// formatting them.
+	TimeAttrUTC bool
+
+	// DurationFormat defines how time.Duration attribute values are encoded.
+	// Defaults to DurationFormatNanos.
+	DurationFormat DurationFormat
+
+	// MaxAttrs, if positive, limits the number of attributes written per
+	// record. A group counts as a single attribute. The attributes over the
+	// limit are dropped, and their count is written in an attrs_truncated
+	// field instead.
+	MaxAttrs int
+
+	// EmitSpanWithoutTrace writes the span ID even when the trace ID is not
+	// available, or is left out because it's not a full resource name and
+	// ProjectID is empty. Note that Cloud Logging only correlates spans within a
+	// trace, so a span ID without a trace is of limited value.
+	EmitSpanWithoutTrace bool
+
+	// NoticeLevel, if set, maps the levels from NoticeLevel up to, but not
+	// including, slog.LevelWarn to the NOTICE severity, e.g.
+	// slog.LevelInfo+2.
+	NoticeLevel slog.Leveler
+
+	// SourceSkipPrefixes leaves out the source location of entries logged
+	// from functions whose fully qualified name starts with any of the
+	// prefixes, e.g. "github.com/some/library.".
+	SourceSkipPrefixes []string
+
+	// OmitEmptyMessage leaves out the message field when the message is
+	// empty.
+	OmitEmptyMessage bool
+
+	// FlattenGroups writes the attributes of groups at the root of the entry
+	// with the group names joined to the keys with GroupSeparator, e.g.
+	// "group.key", instead of as nested objects.
+	FlattenGroups bool
+
+	// NumericGroupsAsArrays writes the groups whose keys are sequential
+	// integers starting from zero, i.e. "0", "1", "2" and so on, as arrays
+	// instead of objects. It has no effect with FlattenGroups.
+	NumericGroupsAsArrays bool
+
+	// GroupSeparator is the separator used with FlattenGroups. Defaults to
+	// ".".
+	GroupSeparator string
+
+	// ShortSourceFunction leaves out the package path and the receiver
+	// decoration from the function of the source location, e.g.
+	// "github.com/a/pkg.(*Type).Method" becomes "Type.Method" and
+	// "github.com/a/pkg.Func" becomes "Func".
+	ShortSourceFunction bool
+
+	// OmitSourceFunction leaves out the function of the source location,
+	// keeping the file and the line.
+	OmitSourceFunction bool
+
+	// SourceStyle defines how the source location is written. Defaults to
+	// SourceStyleObject.
+	SourceStyle SourceStyle
+
+	// Name, if set, is written to the "logger" field of the entries to
+	// identify the logger that produced them. See also Handler.Named.
+	Name string
+
+	// Redact, if set, is called for each attr, including the attrs passed to
+	// WithAttrs and the attrs nested in groups, with the names of the groups
+	// the attr is in, and the key and the resolved value of the attr. If it
+	// returns true, the returned value is written instead of the original
+	// one.
+	Redact func(groups []string, key string, v slog.Value) (slog.Value, bool)
+
+	// DefaultLevel, if set, is the level used for records with the zero
+	// level. By default, the zero level is slog.LevelInfo and maps to the
+	// INFO severity. Note that slog.LevelInfo is the zero level, so this
+	// also applies to the records logged with e.g. Logger.Info.
+	DefaultLevel slog.Leveler
+
+	// EmitNumericSeverity writes the LogSeverity enum value of the severity,
+	// e.g. 400 for WARNING, to a "severityNumber" field in addition to the
+	// severity field.
+	//
+	// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+	EmitNumericSeverity bool
+
+	// StrictSeverity makes Handle return an error for the top-level string
+	// attrs with SeverityAttrKey whose values aren't LogSeverity names. The
+	// entry is still written with the severity of its level, and the attrs
+	// are written to the payload.
+	StrictSeverity bool
+
+	// IncludeLevelName writes the name of the slog level, e.g. "INFO" or
+	// "DEBUG+2", to a "level" field in addition to the severity field.
+	// LevelCritical is named "CRITICAL".
+	IncludeLevelName bool
+
+	// Resource, if it has a Type, is written to the resource field of the
+	// entries. The logging agent ignores the field, so it's only useful when
+	// shipping the entries with the Cloud Logging API, e.g. with the api
+	// package. See also WithResource.
+	Resource MonitoredResource
+
+	// StructTag, if set, is the struct tag used for the keys of the struct
+	// fields in attr values instead of the json tag, e.g. "log". By default,
+	// the attr values not handled otherwise are encoded with encoding/json,
+	// so the json tags apply. As with the json tag, the "-" name and the
+	// omitempty option are supported. Values implementing json.Marshaler or
+	// encoding.TextMarshaler are encoded with their own marshaling.
+	StructTag string
+
+	// ErrorIncludeType writes the error attr values as records with the
+	// message and the concrete type of the error, e.g.
+	// {"message":"...","type":"*fmt.wrapError"}, instead of just the message.
+	// Errors implementing json.Marshaler keep their own marshaling.
+	ErrorIncludeType bool
+
+	// MessageFormatter, if set, is called to produce the message of the
+	// entries, e.g. to interpolate some of the attrs of the record into the
+	// message. The attrs are still written as usual.
+	MessageFormatter func(msg string, r *slog.Record) string
+
+	// AllowedKeys, if set, leaves out the attrs whose keys are not in the
+	// list, at all nesting levels. The fields written by the Handler itself,
+	// such as the message and the labels, are always kept.
+	AllowedKeys []string
+
+	// SanitizeStrings strips the ASCII control characters, except for tabs
+	// and line breaks, from the message and the string attr values.
+	SanitizeStrings bool
+
+	// FixInvalidUTF8 replaces the invalid UTF-8 byte sequences in the message
+	// and the string attr values with U+FFFD, the replacement character. The
+	// default encoder does so regardless, but other encoders set with
+	// NewEncoder may not produce valid JSON from invalid UTF-8.
+	FixInvalidUTF8 bool
+
+	// DedupAttrs defines how the attrs with duplicate keys on the same
+	// level, including the attrs passed to WithAttrs, are written. Defaults
+	// to DedupNone. Other modes prevent encoding the attrs passed to
+	// WithAttrs ahead of time, making the handling of the records slower.
+	DedupAttrs DedupMode
+
+	// SortKeys writes the attrs, including the attrs passed to WithAttrs,
+	// sorted by their keys on each level, e.g. for stable diffs in snapshot
+	// tests. Like DedupAttrs, it prevents encoding the attrs passed to
+	// WithAttrs ahead of time.
+	SortKeys bool
+
+	// MinSeverity and MaxSeverity, if set, clamp the severity of the entries
+	// to the severities of the levels, e.g. slog.LevelInfo and
+	// slog.LevelError, for sinks that reject entries outside of a severity
+	// range. Unlike Level, they don't filter out any entries.
+	MinSeverity slog.Leveler
+	MaxSeverity slog.Leveler
+
+	// LabelStyle defines how the labels are written. Defaults to
+	// LabelStyleNested.
+	LabelStyle LabelStyle
+
+	// SortLabels writes the labels sorted by their keys, e.g. for stable
+	// diffs in snapshot tests, keeping only the last label with each key.
+	SortLabels bool
+
+	// EmitReceiveTimestamp writes the time the entry is written, as returned
+	// by Now, to a "receiveTimestamp" field in addition to the timestamp of
+	// the record, e.g. for analyzing the latency of the logging.
+	EmitReceiveTimestamp bool
+
+	// NumbersAsStrings writes the int64, uint64, float64 and duration attrs
+	// as strings, e.g. "123", for schemas that expect string fields. The
+	// strings preserve the full precision of the numbers.
+	NumbersAsStrings bool
+
+	// RecordSeparator is written before each entry in the same write, e.g.
+	// 0x1e for RFC 7464 JSON text sequences. Defaults to none.
+	RecordSeparator byte
+
+	// RequestIDKey overrides the key of the field the request ID stored in
+	// the Context with WithRequestID is written to. Defaults to
+	// "request_id".
+	RequestIDKey string
+
+	// DisableLogValuerResolution writes the values implementing
+	// slog.LogValuer as is, marshaling their fields, instead of the values
+	// returned by their LogValue methods.
+	DisableLogValuerResolution bool
+
+	// IncludeUptime writes the milliseconds elapsed since the creation of the
+	// Handler, as measured by Now, to an "uptime_ms" field, e.g. for rough
+	// profiling in local runs.
+	IncludeUptime bool
+
+	// IncludeGoroutineID writes the ID of the goroutine calling Handle to a
+	// "goroutine" field, e.g. for debugging concurrency issues. Go doesn't
+	// expose the ID, so it's parsed from a truncated runtime.Stack trace,
+	// which costs a few microseconds per entry.
+	IncludeGoroutineID bool
+
+	// IncludeDeadline writes the milliseconds remaining until the deadline of
+	// the context, as measured by Now, to a "deadline_ms" field, e.g. for
+	// debugging timeouts. The value is negative if the deadline has been
+	// exceeded. The field is left out if the context has no deadline.
+	IncludeDeadline bool
+
+	// NewEncoder, if set, creates the Encoder used for encoding the entries
+	// instead of goldjson. Like DedupAttrs, it prevents encoding the attrs
+	// passed to WithAttrs ahead of time.
+	NewEncoder NewEncoderFunc
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	// IncludeBuildRevision adds the VCS revision the binary was built from to
+	// the labels of every entry as "vcs.revision", making the entries
+	// traceable to a commit. The label is omitted if the binary wasn't built
+	// with VCS information.
+	IncludeBuildRevision bool
+
+	// EmitStartupRecord writes a record with the start time, the PID and the
+	// version of the process under a "startup" field when the Handler is
+	// created, as an anchor for log viewers. The record is written once, before
+	// any entries, and has none of the fields of the entries besides the
+	// message.
+	EmitStartupRecord bool
+
+	// FlushInterval enables syncing the writer in the background at the
+	// interval, if it has a Sync method, such as os.File. The syncing is
+	// stopped by Handler.Close, which also does a final sync. Errors from
+	// the background syncs are reported to OnError.
+	FlushInterval time.Duration
+}
+
+// ServiceContext identifies the service and its version that reported an
+// error.
+type ServiceContext struct {
+	Service string
+	Version string
 }
 
 // Handler is a handler that writes the log entries in the stackdriver logging
 // JSON format.
+//
+// The fields of an entry are written in a deterministic order: first the
+// special fields, then the attrs added with WithAttrs in the order they were
+// added, each within the groups opened with WithGroup before them, and
+// finally the attrs of the record in their order within the innermost group.
+// The groups are left out if no attrs would be written into them. With
+// Config.SortKeys, the attrs on each level are sorted by their keys instead.
 type Handler struct {
-	encoder      *goldjson.Encoder
-	config       Config
-	attrBuilders []func(ctx context.Context, h *Handler, l *goldjson.LineWriter, next func(context.Context) error) error
+	writer           *fullWriter
+	syncLoop         *syncLoop
+	start            time.Time
+	encoder          *goldjson.Encoder
+	customEncoder    Encoder
+	config           Config
+	attrBuilders     []func(ctx context.Context, h *Handler, l LineWriter, next func(context.Context) error) error
+	stats            *stats
+	hasErrorAttr     bool
+	attrsEnd         int
+	groupPrefix      string
+	groups           []string
+	inGroup          bool
+	labels           []Label
+	trace            *Trace
+	missingProjectID *sync.Once
+	allowedKeys      map[string]struct{}
+	frames           []attrFrame
 }
 
 // NewHandler returns a new Handler.
 func NewHandler(w io.Writer, config Config) *Handler {
-	encoder := goldjson.NewEncoder(w)
-	encoder.PrepareKey(fieldMessage)
-	encoder.PrepareKey(fieldTimestamp)
-	encoder.PrepareKey(fieldSeverity)
-	encoder.PrepareKey(fieldSourceLocation)
+	if config.MessageKey == "" {
+		config.MessageKey = FieldMessage
+	}
+	if config.RequestIDKey == "" {
+		config.RequestIDKey = fieldRequestID
+	}
+	if config.SeverityKey == "" {
+		config.SeverityKey = FieldSeverity
+	}
+	if config.NestPayload && config.PayloadKey == "" {
+		config.PayloadKey = fieldPayload
+	}
+	if config.GroupSeparator == "" {
+		config.GroupSeparator = "."
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	writer := &fullWriter{w: w, separator: config.RecordSeparator}
+	encoder := goldjson.NewEncoder(writer)
+	encoder.PrepareKey(config.MessageKey)
+	encoder.PrepareKey(FieldTimestamp)
+	encoder.PrepareKey(config.SeverityKey)
+	encoder.PrepareKey(FieldSourceLocation)
 	encoder.PrepareKey(fieldSourceFile)
 	encoder.PrepareKey(fieldSourceLine)
 	encoder.PrepareKey(fieldSourceFunction)
-	encoder.PrepareKey(fieldTraceID)
-	encoder.PrepareKey(fieldTraceSpanID)
-	encoder.PrepareKey(fieldTraceSampled)
-	encoder.PrepareKey(fieldLabels)
-	return &Handler{
-		encoder: encoder,
-		config:  config,
+	encoder.PrepareKey(fieldCaller)
+	encoder.PrepareKey(FieldTrace)
+	encoder.PrepareKey(FieldSpanID)
+	encoder.PrepareKey(FieldTraceSampled)
+	encoder.PrepareKey(FieldLabels)
+	encoder.PrepareKey(FieldInsertID)
+	if config.PayloadKey != "" {
+		encoder.PrepareKey(config.PayloadKey)
+	}
+	encoder.PrepareKey(fieldAttrsTruncated)
+	encoder.PrepareKey(FieldType)
+	encoder.PrepareKey(FieldServiceContext)
+	encoder.PrepareKey(fieldServiceContextService)
+	encoder.PrepareKey(fieldServiceContextVersion)
+	encoder.PrepareKey(fieldLogger)
+	encoder.PrepareKey(fieldSeverityNumber)
+	encoder.PrepareKey(fieldReceiveTimestamp)
+	encoder.PrepareKey(config.RequestIDKey)
+	encoder.PrepareKey(fieldUptime)
+	encoder.PrepareKey(fieldGoroutine)
+	encoder.PrepareKey(fieldDeadline)
+	encoder.PrepareKey(fieldLevel)
+	encoder.PrepareKey(fieldDurationNanos)
+	encoder.PrepareKey(fieldDurationHuman)
+	encoder.PrepareKey(FieldResource)
+	encoder.PrepareKey(fieldResourceType)
+	encoder.PrepareKey(fieldResourceLabels)
+	encoder.PrepareKey(fieldErrorMessage)
+	encoder.PrepareKey(fieldErrorType)
+	encoder.PrepareKey(fieldStackTrace)
+	encoder.PrepareKey(fieldStartup)
+	encoder.PrepareKey(fieldStartupTime)
+	encoder.PrepareKey(fieldStartupPID)
+	var allowedKeys map[string]struct{}
+	if config.AllowedKeys != nil {
+		allowedKeys = make(map[string]struct{}, len(config.AllowedKeys))
+		for _, key := range config.AllowedKeys {
+			allowedKeys[key] = struct{}{}
+		}
+	}
+	var labels []Label
+	if config.IncludeBuildRevision {
+		if revision := buildRevision(); revision != "" {
+			labels = append(labels, NewLabel(labelBuildRevision, revision))
+		}
+	}
+	var start time.Time
+	if config.IncludeUptime {
+		start = config.Now()
 	}
+	var customEncoder Encoder
+	if config.NewEncoder != nil {
+		customEncoder = config.NewEncoder(writer)
+	}
+	var syncLoop *syncLoop
+	if config.FlushInterval > 0 && writer.canSync() {
+		syncLoop = startSyncLoop(writer, config.FlushInterval, config.OnError)
+	}
+	h := &Handler{
+		writer:           writer,
+		syncLoop:         syncLoop,
+		start:            start,
+		encoder:          encoder,
+		customEncoder:    customEncoder,
+		config:           config,
+		stats:            &stats{},
+		missingProjectID: &sync.Once{},
+		labels:           labels,
+		allowedKeys:      allowedKeys,
+	}
+	if config.EmitStartupRecord {
+		if err := h.writeStartupRecord(); err != nil && config.OnError != nil {
+			config.OnError(err)
+		}
+	}
+	return h
 }
 
 // Handle implements slog.Handler.
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	l := h.encoder.NewLine()
+	values := h.resolveAttrs(&r)
+	r.Level = h.level(r.Level)
+	if h.config.EscalateOnError && r.Level < slog.LevelError && h.hasErrorAttrs(&r) {
+		r.Level = slog.LevelError
+	}
+
+	severity, severityErr := h.recordSeverity(&r)
+
+	l := h.newLine()
 
 	h.addMessage(ctx, l, &r)
 	h.addTimestamp(ctx, l, &r)
-	h.addSeverity(ctx, l, &r)
+	h.addReceiveTimestamp(ctx, l, &r)
+	h.addUptime(ctx, l, &r)
+	h.addGoroutineID(ctx, l, &r)
+	h.addDeadline(ctx, l, &r)
+	h.addSeverity(ctx, l, &r, severity)
 	h.addSourceLocation(ctx, l, &r)
-	h.addTrace(ctx, l, &r)
-	h.addLabels(ctx, l, &r)
+	h.addTrace(ctx, l, &r, values.trace)
+	h.addLabels(ctx, l, &r, values.labels)
+	h.addResource(ctx, l, &r)
+	h.addInsertID(ctx, l, &r)
+	h.addErrorReport(ctx, l, &r)
+	h.addName(ctx, l, &r)
+	h.addRequestID(ctx, l, &r)
 
-	err := h.addAttrs(ctx, l, &r)
-	err = errors.Join(err, l.End())
+	err := errors.Join(severityErr, h.addAttrs(ctx, l, &r))
+	if writeErr := l.End(); writeErr != nil {
+		h.stats.addWriteError()
+		err = errors.Join(err, writeErr)
+	} else {
+		h.stats.addEntry(severity)
+	}
+
+	if err != nil && h.config.OnError != nil {
+		h.config.OnError(err)
+	}
 
 	return err
 }
 
+func (h *Handler) newLine() LineWriter {
+	if h.customEncoder != nil {
+		return h.customEncoder.NewLine()
+	}
+	return h.encoder.NewLine()
+}
+
+// Stats returns the counts of the entries written by the Handler and the
+// Handlers derived from it.
+func (h *Handler) Stats() Stats {
+	return h.stats.snapshot()
+}
+
+// Config returns the configuration of the Handler, with the defaults applied.
+func (h *Handler) Config() Config {
+	return h.config
+}
+
+// Flush flushes the underlying writer if it has a Flush method, such as
+// bufio.Writer.
+func (h *Handler) Flush() error {
+	return h.writer.Flush()
+}
+
+// Close stops the background syncing enabled by Config.FlushInterval, then
+// flushes the underlying writer if it has a Flush method and syncs it if it
+// has a Sync method. The underlying writer is not closed.
+func (h *Handler) Close() error {
+	if h.syncLoop != nil {
+		h.syncLoop.Stop()
+	}
+	return errors.Join(h.writer.Flush(), h.writer.Sync())
+}
+
+// Named returns a new Handler with the name appended to the name of the
+// Handler, separated by a dot, e.g. "server.db".
+func (h *Handler) Named(name string) *Handler {
+	clone := *h
+	if h.config.Name == "" {
+		clone.config.Name = name
+	} else if name != "" {
+		clone.config.Name = h.config.Name + "." + name
+	}
+	return &clone
+}
+
 // WithAttrs implements slog.Handler.
 func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	if h.defersAttrs() {
+		return h.withAttrsDedup(as)
+	}
 	clone := *h
 	staticFields, w := goldjson.NewStaticFields()
 	var err error
+	n := 0
+	as, values := h.resolveAttrSlice(as)
+	clone.addValues(values)
 	for _, attr := range as {
-		err = errors.Join(err, h.addAttr(w, attr))
+		if h.isOmittedAttr(attr) {
+			continue
+		}
+		n++
+		err = errors.Join(err, h.addAttr(w, h.groupPrefix, h.groups, attr))
+		clone.hasErrorAttr = clone.hasErrorAttr || isErrorAttr(attr)
+	}
+	if n == 0 {
+		if len(clone.labels) != len(h.labels) || clone.trace != h.trace {
+			return &clone
+		}
+		return h
 	}
 	clone.attrBuilders = cloneAppend(
 		h.attrBuilders,
-		func(ctx context.Context, h *Handler, l *goldjson.LineWriter, next func(context.Context) error) error {
-			l.AddStaticFields(staticFields)
+		func(ctx context.Context, h *Handler, l LineWriter, next func(context.Context) error) error {
+			// the attrs are only encoded ahead of time with goldjson
+			l.(*goldjson.LineWriter).AddStaticFields(staticFields)
 			return errors.Join(err, next(ctx))
 		},
 	)
-	err = w.End()
+	clone.attrsEnd = len(clone.attrBuilders)
+	err = errors.Join(err, w.End())
 	return &clone
 }
 
 // WithGroup implements slog.Handler.
 func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	if h.defersAttrs() {
+		return h.withGroupDedup(name)
+	}
 	clone := *h
+	clone.inGroup = true
+	if h.config.Redact != nil {
+		clone.groups = append(slices.Clip(h.groups), name)
+	}
+	name = h.attrKey(name)
+	if h.config.FlattenGroups {
+		clone.groupPrefix = h.groupPrefix + name + h.config.GroupSeparator
+		return &clone
+	}
 	clone.encoder = h.encoder.Clone()
 	clone.encoder.PrepareKey(name)
 	clone.attrBuilders = cloneAppend(
 		h.attrBuilders,
-		func(ctx context.Context, h *Handler, l *goldjson.LineWriter, next func(context.Context) error) error {
+		func(ctx context.Context, h *Handler, l LineWriter, next func(context.Context) error) error {
 			l.StartRecord(name)
 			defer l.EndRecord()
 			return next(ctx)
@@ -98,78 +649,353 @@ func (h *Handler) WithGroup(name string) slog.Handler {
 	return &clone
 }
 
-// Enabled implements slog.Handler.
+// SetLevel sets the minimum level of the Handler and the Handlers sharing its
+// Config.Level. It returns ErrLevelNotSettable if Config.Level is not a
+// *slog.LevelVar or another Leveler with a Set(slog.Level) method.
+func (h *Handler) SetLevel(level slog.Level) error {
+	v, ok := h.config.Level.(interface{ Set(slog.Level) })
+	if !ok {
+		return ErrLevelNotSettable
+	}
+	v.Set(level)
+	return nil
+}
+
+// ErrMissingProjectID is reported to Config.OnError, once per NewHandler, when
+// the trace fields of an entry are left out because the trace ID is not a full
+// resource name and Config.ProjectID is empty.
+var ErrMissingProjectID = errors.New("slogdriver: trace left out due to missing project ID")
+
+// ErrLevelNotSettable is returned by Handler.SetLevel when Config.Level can't
+// be changed.
+var ErrLevelNotSettable = errors.New("slogdriver: level is not settable")
+
+// Enabled implements slog.Handler. The level is read from Config.Level on
+// every call, so changes to a *slog.LevelVar take effect immediately.
 func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
-	minLevel := slog.LevelInfo
-	if h.config.Level != nil {
-		minLevel = h.config.Level.Level()
+	return h.level(l) >= h.Level()
+}
+
+// Level returns the current minimum level of the Handler, read from
+// Config.Level. Defaults to slog.LevelInfo.
+func (h *Handler) Level() slog.Level {
+	if h.config.Level == nil {
+		return slog.LevelInfo
 	}
-	return l >= minLevel
+	return h.config.Level.Level()
 }
 
-func (h *Handler) addMessage(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
-	l.AddString(fieldMessage, r.Message)
+// level returns the level with the zero level replaced by DefaultLevel.
+func (h *Handler) level(l slog.Level) slog.Level {
+	if l == 0 && h.config.DefaultLevel != nil {
+		return h.config.DefaultLevel.Level()
+	}
+	return l
 }
 
-func (h *Handler) addTimestamp(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
+func (h *Handler) addMessage(ctx context.Context, l LineWriter, r *slog.Record) {
+	msg := r.Message
+	if h.config.MessageFormatter != nil {
+		msg = h.config.MessageFormatter(msg, r)
+	}
+	msg = h.cleanString(msg)
+	if h.config.OmitEmptyMessage && msg == "" {
+		return
+	}
+	l.AddString(h.config.MessageKey, msg)
+}
+
+func (h *Handler) addTimestamp(ctx context.Context, l LineWriter, r *slog.Record) {
+	if h.config.OmitTimestamp || r.Time.IsZero() {
+		return
+	}
 	time := r.Time.Round(0) // strip monotonic to match Attr behavior
-	l.AddTime(fieldTimestamp, time)
+	if h.config.TimestampUTC {
+		time = time.UTC()
+	}
+	l.AddTime(FieldTimestamp, time)
 }
 
-func (h *Handler) addSeverity(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
-	switch {
-	case r.Level >= slog.LevelError:
-		l.AddUint64(fieldSeverity, severityError)
-	case r.Level >= slog.LevelWarn:
-		l.AddUint64(fieldSeverity, severityWarn)
-	case r.Level >= slog.LevelInfo:
-		l.AddUint64(fieldSeverity, severityInfo)
+func (h *Handler) addReceiveTimestamp(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.EmitReceiveTimestamp {
+		return
+	}
+	l.AddTime(fieldReceiveTimestamp, h.config.Now().Round(0))
+}
+
+func (h *Handler) addUptime(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.IncludeUptime {
+		return
+	}
+	l.AddInt64(fieldUptime, h.config.Now().Sub(h.start).Milliseconds())
+}
+
+func (h *Handler) addGoroutineID(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.IncludeGoroutineID {
+		return
+	}
+	l.AddUint64(fieldGoroutine, goroutineID())
+}
+
+func (h *Handler) addDeadline(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.IncludeDeadline {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	l.AddInt64(fieldDeadline, deadline.Sub(h.config.Now()).Milliseconds())
+}
+
+func (h *Handler) addSeverity(ctx context.Context, l LineWriter, r *slog.Record, severity severity) {
+	switch h.config.SeverityFormat {
+	case SeverityFormatString:
+		name := severity.Name()
+		if h.config.LowercaseSeverity {
+			name = strings.ToLower(name)
+		}
+		l.AddString(h.config.SeverityKey, name)
+	case SeverityFormatEnum:
+		l.AddUint64(h.config.SeverityKey, severity.Enum())
 	default:
-		l.AddUint64(fieldSeverity, severityDebug)
+		l.AddUint64(h.config.SeverityKey, severity.Number())
+	}
+	if h.config.EmitNumericSeverity {
+		l.AddUint64(fieldSeverityNumber, severity.Enum())
+	}
+	if h.config.IncludeLevelName {
+		l.AddString(fieldLevel, levelName(r.Level))
 	}
 }
 
-func (h *Handler) addSourceLocation(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
+func (h *Handler) addSourceLocation(ctx context.Context, l LineWriter, r *slog.Record) {
+	if r.PC == 0 {
+		return
+	}
 	fs := runtime.CallersFrames([]uintptr{r.PC})
 	f, _ := fs.Next()
 
-	l.StartRecord(fieldSourceLocation)
+	for _, prefix := range h.config.SourceSkipPrefixes {
+		if strings.HasPrefix(f.Function, prefix) {
+			return
+		}
+	}
+
+	if h.config.SourceStyle == SourceStyleString {
+		l.AddString(fieldCaller, filepath.Base(f.File)+":"+strconv.Itoa(f.Line))
+		return
+	}
+
+	l.StartRecord(FieldSourceLocation)
 	defer l.EndRecord()
 
 	l.AddString(fieldSourceFile, f.File)
 	l.AddInt64(fieldSourceLine, int64(f.Line))
-	l.AddString(fieldSourceFunction, f.Function)
+	switch {
+	case h.config.OmitSourceFunction:
+	case h.config.ShortSourceFunction:
+		l.AddString(fieldSourceFunction, shortFunction(f.Function))
+	default:
+		l.AddString(fieldSourceFunction, f.Function)
+	}
 }
 
-func (h *Handler) addTrace(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
+// shortFunction strips the package path and the pointer receiver decoration
+// from a fully qualified function name.
+func shortFunction(name string) string {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if strings.HasPrefix(name, "(*") {
+		if i := strings.Index(name, ")."); i >= 0 {
+			name = name[2:i] + name[i+1:]
+		}
+	}
+	return name
+}
+
+func (h *Handler) addTrace(ctx context.Context, l LineWriter, r *slog.Record, attrTrace *Trace) {
 	trace := traceFromContext(ctx)
+	if h.trace != nil {
+		trace = *h.trace
+	}
+	if attrTrace != nil {
+		trace = *attrTrace
+	}
+	if h.config.ProjectID == "" && trace.ID != "" && !strings.HasPrefix(trace.ID, "projects/") {
+		// a bare trace ID can't be turned into a valid resource name
+		h.missingProjectID.Do(func() {
+			if h.config.OnError != nil {
+				h.config.OnError(ErrMissingProjectID)
+			}
+		})
+		trace.ID = ""
+	}
 	if trace.ID == "" {
+		if h.config.EmitSpanWithoutTrace && trace.SpanID != "" {
+			l.AddString(FieldSpanID, trace.SpanID)
+		}
 		return
 	}
+	if isForceSampled(ctx) {
+		trace.Sampled = true
+	}
 
-	l.AddString(fieldTraceID, fmt.Sprintf("projects/%s/traces/%s", h.config.ProjectID, trace.ID))
+	if strings.HasPrefix(trace.ID, "projects/") {
+		l.AddString(FieldTrace, trace.ID)
+	} else {
+		l.AddString(FieldTrace, fmt.Sprintf("projects/%s/traces/%s", h.config.ProjectID, trace.ID))
+	}
 	if trace.SpanID != "" {
-		l.AddString(fieldTraceSpanID, trace.SpanID)
+		l.AddString(FieldSpanID, trace.SpanID)
+	}
+	if trace.Sampled || !h.config.OmitUnsampledFlag {
+		l.AddBool(FieldTraceSampled, trace.Sampled)
 	}
-	l.AddBool(fieldTraceSampled, trace.Sampled)
 }
 
-func (h *Handler) addLabels(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) {
+func (h *Handler) addLabels(ctx context.Context, l LineWriter, r *slog.Record, attrLabels []Label) {
 	opened := false
-	labelsFromContext(ctx).Iterate(func(label Label) {
+	add := func(label Label) {
+		if h.config.LabelStyle == LabelStylePrefixed {
+			l.AddString(labelPrefix+label.Key, label.Value)
+			return
+		}
 		if !opened {
 			opened = true
-			l.StartRecord("logging.googleapis.com/labels")
+			l.StartRecord(FieldLabels)
 		}
 		l.AddString(label.Key, label.Value)
-	})
+	}
+	if h.config.SortLabels {
+		for _, label := range h.sortedLabels(ctx, attrLabels) {
+			add(label)
+		}
+	} else {
+		h.iterateLabels(ctx, attrLabels, add)
+	}
 	if opened {
 		l.EndRecord()
 	}
 }
 
-func (h *Handler) addAttrs(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) error {
-	if len(h.attrBuilders) == 0 {
+func (h *Handler) iterateLabels(ctx context.Context, attrLabels []Label, f func(Label)) {
+	labelsFromContext(ctx).Iterate(f)
+	for _, label := range h.labels {
+		f(label)
+	}
+	for _, label := range attrLabels {
+		f(label)
+	}
+}
+
+// sortedLabels returns the labels of the entry sorted by their keys, keeping
+// only the last label with each key.
+func (h *Handler) sortedLabels(ctx context.Context, attrLabels []Label) []Label {
+	var labels []Label
+	h.iterateLabels(ctx, attrLabels, func(label Label) {
+		labels = append(labels, label)
+	})
+	slices.SortStableFunc(labels, func(a, b Label) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	result := labels[:0]
+	for i, label := range labels {
+		if i+1 == len(labels) || labels[i+1].Key != label.Key {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+func (h *Handler) addResource(ctx context.Context, l LineWriter, r *slog.Record) {
+	resource := resourceFromContext(ctx)
+	if resource.Type == "" {
+		resource = h.config.Resource
+	}
+	if resource.Type == "" {
+		return
+	}
+
+	l.StartRecord(FieldResource)
+	defer l.EndRecord()
+
+	l.AddString(fieldResourceType, resource.Type)
+	if len(resource.Labels) != 0 {
+		h.addStringMap(l, fieldResourceLabels, resource.Labels)
+	}
+}
+
+func (h *Handler) addInsertID(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.GenerateInsertID {
+		return
+	}
+	l.AddString(FieldInsertID, nextInsertID())
+}
+
+func (h *Handler) addName(ctx context.Context, l LineWriter, r *slog.Record) {
+	if h.config.Name == "" {
+		return
+	}
+	l.AddString(fieldLogger, h.config.Name)
+}
+
+func (h *Handler) addRequestID(ctx context.Context, l LineWriter, r *slog.Record) {
+	id := requestIDFromContext(ctx)
+	if id == "" {
+		return
+	}
+	l.AddString(h.config.RequestIDKey, id)
+}
+
+func (h *Handler) addErrorReport(ctx context.Context, l LineWriter, r *slog.Record) {
+	if !h.config.ReportErrors || r.Level < slog.LevelError {
+		return
+	}
+
+	l.AddString(FieldType, typeReportedErrorEvent)
+	if h.config.ReportStackTraces {
+		l.AddString(fieldStackTrace, h.stackTrace(r))
+	}
+
+	if h.config.ServiceContext.Service == "" {
+		return
+	}
+
+	l.StartRecord(FieldServiceContext)
+	defer l.EndRecord()
+
+	l.AddString(fieldServiceContextService, h.config.ServiceContext.Service)
+	if h.config.ServiceContext.Version != "" {
+		l.AddString(fieldServiceContextVersion, h.config.ServiceContext.Version)
+	}
+}
+
+func (h *Handler) addAttrs(ctx context.Context, l LineWriter, r *slog.Record) error {
+	if h.defersAttrs() {
+		return h.addAttrsDedup(ctx, l, r)
+	}
+	if h.attrsEnd == 0 && r.NumAttrs() == 0 {
+		return nil
+	}
+
+	hasAttrs := h.hasWrittenAttrs(r)
+	truncated := h.truncatedAttrs(r)
+	attrBuilders := h.attrBuilders
+	if !hasAttrs {
+		// the groups after the last attrs would be left empty
+		attrBuilders = attrBuilders[:h.attrsEnd]
+	}
+
+	endPayload := h.startPayload(l, len(attrBuilders) == 0 && !hasAttrs && truncated == 0)
+	defer endPayload()
+	defer addTruncated(l, truncated)
+
+	if len(attrBuilders) == 0 {
 		return h.addAttrsRaw(ctx, l, r)
 	}
 
@@ -177,8 +1003,8 @@ func (h *Handler) addAttrs(ctx context.Context, l *goldjson.LineWriter, r *slog.
 		return h.addAttrsRaw(ctx, l, r)
 	}
 
-	for i := range h.attrBuilders {
-		attrBuilder := h.attrBuilders[len(h.attrBuilders)-1-i]
+	for i := range attrBuilders {
+		attrBuilder := attrBuilders[len(attrBuilders)-1-i]
 		next := b
 		b = func(ctx context.Context) error {
 			return attrBuilder(ctx, h, l, next)
@@ -188,91 +1014,642 @@ func (h *Handler) addAttrs(ctx context.Context, l *goldjson.LineWriter, r *slog.
 	return b(ctx)
 }
 
-func (h *Handler) addAttrsRaw(ctx context.Context, l *goldjson.LineWriter, r *slog.Record) error {
+func (h *Handler) addAttrsRaw(ctx context.Context, l LineWriter, r *slog.Record) error {
 	var err error
-	r.Attrs(func(attr slog.Attr) bool {
-		err = errors.Join(err, h.addAttr(l, attr))
-		return true
+	h.recordAttrs(r, func(attr slog.Attr) {
+		err = errors.Join(err, h.addAttr(l, h.groupPrefix, h.groups, attr))
 	})
 	return err
 }
 
-func (h *Handler) addAttr(l *goldjson.LineWriter, a slog.Attr) error {
-	v := a.Value.Resolve()
+// recordAttrs calls f for the attrs of the record that are written to the
+// payload, i.e. the ones within Config.MaxAttrs that don't override the
+// severity.
+func (h *Handler) recordAttrs(r *slog.Record, f func(slog.Attr)) {
+	n := 0
+	r.Attrs(func(attr slog.Attr) bool {
+		if !h.isSeverityAttr(attr) {
+			f(attr)
+		}
+		n++
+		return h.config.MaxAttrs <= 0 || n < h.config.MaxAttrs
+	})
+}
+
+// hasWrittenAttrs reports whether any of the attrs of the record produce
+// output in the payload.
+func (h *Handler) hasWrittenAttrs(r *slog.Record) bool {
+	found := false
+	h.recordAttrs(r, func(attr slog.Attr) {
+		found = found || !h.isOmittedAttr(attr)
+	})
+	return found
+}
+
+// truncatedAttrs returns the number of the attrs of the record left out due
+// to Config.MaxAttrs.
+func (h *Handler) truncatedAttrs(r *slog.Record) int {
+	if h.config.MaxAttrs <= 0 {
+		return 0
+	}
+	return max(r.NumAttrs()-h.config.MaxAttrs, 0)
+}
+
+// addTruncated writes the number of the attrs left out due to
+// Config.MaxAttrs, if any, at the root of the payload.
+func addTruncated(l LineWriter, truncated int) {
+	if truncated != 0 {
+		l.AddInt64(fieldAttrsTruncated, int64(truncated))
+	}
+}
+
+// startPayload opens the Config.PayloadKey object, if set and the payload
+// isn't empty, and returns a function that closes it.
+func (h *Handler) startPayload(l LineWriter, empty bool) func() {
+	if h.config.PayloadKey == "" || empty {
+		return func() {}
+	}
+	l.StartRecord(h.config.PayloadKey)
+	return l.EndRecord
+}
+
+func (h *Handler) addAttr(l LineWriter, prefix string, groups []string, a slog.Attr) error {
+	// resolved ahead of the omission check, so that the LogValuers resolving
+	// to Label and Trace values are routed out of the payload
+	a.Value = h.resolve(a.Value)
+	if h.isOmittedAttr(a) {
+		return nil
+	}
+	v := a.Value
+	if h.config.Redact != nil {
+		if redacted, ok := h.config.Redact(groups, a.Key, v); ok {
+			v = h.resolve(redacted)
+		}
+	}
+	key := prefix + h.attrKey(a.Key)
 	switch v.Kind() {
 	case slog.KindGroup:
-		return h.addGroup(l, a, v)
+		return h.addGroup(l, prefix, groups, a, v)
 	case slog.KindString:
-		l.AddString(a.Key, v.String())
+		l.AddString(key, h.cleanString(v.String()))
 		return nil
 	case slog.KindInt64:
-		l.AddInt64(a.Key, v.Int64())
+		h.addInt64(l, key, v.Int64())
 		return nil
 	case slog.KindUint64:
-		l.AddUint64(a.Key, v.Uint64())
+		h.addUint64(l, key, v.Uint64())
 		return nil
 	case slog.KindFloat64:
-		l.AddFloat64(a.Key, v.Float64())
+		h.addFloat64(l, key, v.Float64())
 		return nil
 	case slog.KindBool:
-		l.AddBool(a.Key, v.Bool())
+		l.AddBool(key, v.Bool())
 		return nil
 	case slog.KindDuration:
-		l.AddInt64(a.Key, int64(v.Duration()))
+		h.addDuration(l, key, v.Duration())
 		return nil
 	case slog.KindTime:
-		return l.AddTime(a.Key, v.Time())
-	case slog.KindAny:
-		return h.addAny(l, a, v)
+		return h.addTime(l, key, v.Time())
+	case slog.KindAny, slog.KindLogValuer:
+		return h.addAny(l, prefix, groups, a, v)
 	}
 	return fmt.Errorf("bad kind: %s", v.Kind())
 }
 
-func (h *Handler) addGroup(l *goldjson.LineWriter, a slog.Attr, v slog.Value) error {
+// attrValues contains the labels and the trace of the attrs of a record.
+type attrValues struct {
+	labels []Label
+	trace  *Trace
+}
+
+// resolveAttrs replaces the attrs of the record with ones whose LogValuers
+// are resolved, including the ones nested in groups, so that the later passes
+// over the attrs don't call LogValue again. The labels and the trace of the
+// attrs are collected in the same pass.
+func (h *Handler) resolveAttrs(r *slog.Record) attrValues {
+	var values attrValues
+	if r.NumAttrs() == 0 {
+		return values
+	}
+	var attrs []slog.Attr
+	i := 0
+	r.Attrs(func(a slog.Attr) bool {
+		var changed bool
+		a.Value, changed = h.resolveValue(a.Value, &values)
+		if changed && attrs == nil {
+			// the attrs before the first resolved one are copied as is
+			attrs = make([]slog.Attr, 0, r.NumAttrs())
+			r.Attrs(func(a slog.Attr) bool {
+				if len(attrs) == i {
+					return false
+				}
+				attrs = append(attrs, a)
+				return true
+			})
+		}
+		if attrs != nil {
+			attrs = append(attrs, a)
+		}
+		i++
+		return true
+	})
+	if attrs != nil {
+		resolved := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		resolved.AddAttrs(attrs...)
+		*r = resolved
+	}
+	return values
+}
+
+// resolveAttrSlice returns a copy of the attrs with their LogValuers resolved,
+// including the ones nested in groups, along with their labels and trace, so
+// that the attrs passed to WithAttrs are resolved only once.
+func (h *Handler) resolveAttrSlice(as []slog.Attr) ([]slog.Attr, attrValues) {
+	var values attrValues
+	resolved := make([]slog.Attr, len(as))
+	for i, a := range as {
+		a.Value, _ = h.resolveValue(a.Value, &values)
+		resolved[i] = a
+	}
+	return resolved, values
+}
+
+// addValues adds the labels and the trace of the attrs passed to WithAttrs
+// to the Handler.
+func (h *Handler) addValues(values attrValues) {
+	h.labels = append(slices.Clip(h.labels), values.labels...)
+	if values.trace != nil {
+		h.trace = values.trace
+	}
+}
+
+// resolveValue returns the value with its LogValuers resolved, and whether
+// that changed the value, adding its labels and trace to values.
+func (h *Handler) resolveValue(v slog.Value, values *attrValues) (slog.Value, bool) {
+	v, changed := h.resolveLogValuer(v)
+	switch v.Kind() {
+	case slog.KindAny:
+		switch t := v.Any().(type) {
+		case Label:
+			values.labels = append(values.labels, t)
+		case Trace:
+			values.trace = &t
+		}
+	case slog.KindGroup:
+		group := v.Group()
+		var resolved []slog.Attr
+		for i, a := range group {
+			rv, ok := h.resolveValue(a.Value, values)
+			if ok && resolved == nil {
+				resolved = slices.Clone(group)
+			}
+			if resolved != nil {
+				resolved[i].Value = rv
+			}
+		}
+		if resolved != nil {
+			v = slog.GroupValue(resolved...)
+			changed = true
+		}
+	}
+	return v, changed
+}
+
+// resolve resolves the slog.LogValuer values, unless
+// DisableLogValuerResolution is set. Values that implement slog.LogValuer only
+// through a pointer are resolved too, but the values their LogValue returns
+// aren't addressed again, so that e.g. returning slog.AnyValue(*t) doesn't
+// recurse.
+func (h *Handler) resolve(v slog.Value) slog.Value {
+	v, _ = h.resolveLogValuer(v)
+	return v
+}
+
+// resolveLogValuer is like resolve, but also reports whether the value was a
+// slog.LogValuer that got resolved.
+func (h *Handler) resolveLogValuer(v slog.Value) (slog.Value, bool) {
+	if h.config.DisableLogValuerResolution {
+		return v, false
+	}
+	if v.Kind() == slog.KindAny {
+		if lv, ok := addressLogValuer(v.Any()); ok {
+			v = slog.AnyValue(lv)
+		}
+	}
+	if v.Kind() != slog.KindLogValuer {
+		return v, false
+	}
+	return v.Resolve(), true
+}
+
+func (h *Handler) addTime(l LineWriter, key string, t time.Time) error {
+	if h.config.TimeAttrUTC {
+		t = t.UTC()
+	}
+	if h.config.TimeAttrFormat != "" {
+		l.AddString(key, t.Format(h.config.TimeAttrFormat))
+		return nil
+	}
+	return l.AddTime(key, t)
+}
+
+func (h *Handler) addDuration(l LineWriter, key string, d time.Duration) {
+	if h.config.DurationFormat != DurationFormatObject {
+		h.addNanos(l, key, d)
+		return
+	}
+	l.StartRecord(key)
+	defer l.EndRecord()
+	h.addNanos(l, fieldDurationNanos, d)
+	l.AddString(fieldDurationHuman, d.String())
+}
+
+func (h *Handler) addNanos(l LineWriter, key string, d time.Duration) {
+	h.addInt64(l, key, int64(d))
+}
+
+func (h *Handler) addInt64(l LineWriter, key string, n int64) {
+	if h.config.NumbersAsStrings {
+		l.AddString(key, strconv.FormatInt(n, 10))
+		return
+	}
+	l.AddInt64(key, n)
+}
+
+func (h *Handler) addUint64(l LineWriter, key string, n uint64) {
+	if h.config.NumbersAsStrings {
+		l.AddString(key, strconv.FormatUint(n, 10))
+		return
+	}
+	l.AddUint64(key, n)
+}
+
+func (h *Handler) addFloat64(l LineWriter, key string, f float64) {
+	if h.config.NumbersAsStrings {
+		l.AddString(key, strconv.FormatFloat(f, 'g', -1, 64))
+		return
+	}
+	l.AddFloat64(key, f)
+}
+
+func (h *Handler) addGroup(l LineWriter, prefix string, groups []string, a slog.Attr, v slog.Value) error {
 	attrs := v.Group()
 	if len(attrs) == 0 {
 		return nil
 	}
-	l.StartRecord(a.Key)
-	defer l.EndRecord()
+	if a.Key != "" && h.config.Redact != nil {
+		groups = append(slices.Clip(groups), a.Key)
+	}
+	switch {
+	case a.Key == "":
+	case h.config.FlattenGroups:
+		prefix += h.attrKey(a.Key) + h.config.GroupSeparator
+	case h.config.NumericGroupsAsArrays && hasSequentialKeys(attrs):
+		l.StartList(prefix + h.attrKey(a.Key))
+		defer l.EndList()
+	default:
+		l.StartRecord(prefix + h.attrKey(a.Key))
+		defer l.EndRecord()
+	}
 	var err error
 	for _, a := range attrs {
-		err = errors.Join(err, h.addAttr(l, a))
+		err = errors.Join(err, h.addAttr(l, prefix, groups, a))
 	}
 	return err
 }
 
-func (h *Handler) addAny(l *goldjson.LineWriter, a slog.Attr, v slog.Value) error {
+// hasSequentialKeys reports whether the keys of the attrs are "0", "1", "2"
+// and so on.
+func hasSequentialKeys(attrs []slog.Attr) bool {
+	for i, a := range attrs {
+		if a.Key != strconv.Itoa(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) addAny(l LineWriter, prefix string, groups []string, a slog.Attr, v slog.Value) error {
 	val := v.Any()
+	switch val := val.(type) {
+	case []slog.Attr:
+		return h.addGroup(l, prefix, groups, a, slog.GroupValue(val...))
+	case slog.Value:
+		return h.addAttr(l, prefix, groups, slog.Attr{Key: a.Key, Value: val})
+	}
+	key := prefix + h.attrKey(a.Key)
+	if isNil(val) {
+		// no methods are called on nil values, as e.g. Error might panic
+		return l.AddMarshal(key, nil)
+	}
+	switch val := val.(type) {
+	case map[string]string:
+		h.addStringMap(l, key, val)
+		return nil
+	case map[string]any:
+		return h.addMap(l, key, val)
+	case []any:
+		return h.addList(l, key, groups, val)
+	}
 	_, jm := val.(json.Marshaler)
 	if err, ok := val.(error); ok && !jm {
-		l.AddString(a.Key, err.Error())
+		if h.config.ErrorIncludeType {
+			l.StartRecord(key)
+			defer l.EndRecord()
+			l.AddString(fieldErrorMessage, err.Error())
+			l.AddString(fieldErrorType, fmt.Sprintf("%T", err))
+			return nil
+		}
+		l.AddString(key, err.Error())
+		return nil
+	}
+	if h.config.StructTag != "" && h.config.StructTag != "json" {
+		return h.addTagged(l, key, reflect.ValueOf(val), map[taggedRef]struct{}{})
+	}
+	return addMarshal(l, key, val)
+}
+
+// isNil reports whether the value is nil or a nil pointer, map or slice.
+func isNil(val any) bool {
+	if val == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(val); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// addressLogValuer returns a pointer to a copy of the value if the value
+// doesn't implement slog.LogValuer, but a pointer to it does.
+func addressLogValuer(val any) (slog.LogValuer, bool) {
+	t := reflect.TypeOf(val)
+	if t == nil || t.Kind() == reflect.Pointer || !reflect.PointerTo(t).Implements(logValuerType) {
+		return nil, false
+	}
+	p := reflect.New(t)
+	p.Elem().Set(reflect.ValueOf(val))
+	return p.Interface().(slog.LogValuer), true
+}
+
+var logValuerType = reflect.TypeOf((*slog.LogValuer)(nil)).Elem()
+
+// addList writes the slice as a list, encoding each element the same way as
+// an attr value, e.g. so that errors become strings.
+func (h *Handler) addList(l LineWriter, key string, groups []string, list []any) error {
+	l.StartList(key)
+	defer l.EndList()
+	var err error
+	for _, v := range list {
+		err = errors.Join(err, h.addListValue(l, groups, v))
+	}
+	return err
+}
+
+func (h *Handler) addListValue(l LineWriter, groups []string, v any) error {
+	if v == nil {
+		return l.AddMarshal("", nil)
+	}
+	value := h.resolve(slog.AnyValue(v))
+	if value.Kind() != slog.KindGroup {
+		return h.addAttr(l, "", groups, slog.Attr{Value: value})
+	}
+	l.StartRecord("")
+	defer l.EndRecord()
+	var err error
+	for _, a := range value.Group() {
+		err = errors.Join(err, h.addAttr(l, "", groups, a))
+	}
+	return err
+}
+
+func (h *Handler) hasErrorAttrs(r *slog.Record) bool {
+	found := h.hasErrorAttr
+	r.Attrs(func(attr slog.Attr) bool {
+		found = found || isErrorAttr(attr)
+		return !found
+	})
+	return found
+}
+
+// cleanString applies Config.SanitizeStrings and Config.FixInvalidUTF8 to the
+// string.
+func (h *Handler) cleanString(s string) string {
+	if h.config.FixInvalidUTF8 && !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if h.config.SanitizeStrings {
+		s = sanitizeString(s)
+	}
+	return s
+}
+
+// sanitizeString strips the ASCII control characters other than tabs and line
+// breaks from the string.
+func sanitizeString(s string) string {
+	if strings.IndexFunc(s, isStrippedControl) == -1 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if isStrippedControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isStrippedControl(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	}
+	return r < 0x20 || r == 0x7f
+}
+
+// isOmittedAttr reports whether the attr is empty or left out due to
+// AllowedKeys.
+func (h *Handler) isOmittedAttr(a slog.Attr) bool {
+	if h.allowedKeys == nil {
+		return h.isEmptyAttr(a)
+	}
+	if _, ok := h.allowedKeys[a.Key]; !ok && a.Key != "" {
+		return true
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, a := range a.Value.Group() {
+			if !h.isOmittedAttr(a) {
+				return false
+			}
+		}
+		return true
+	}
+	return h.isEmptyAttr(a)
+}
+
+// isEmptyAttr reports whether the attr produces no output in the payload. Attrs
+// with Label and Trace values are written to the labels and the trace fields
+// instead.
+func (h *Handler) isEmptyAttr(a slog.Attr) bool {
+	v := h.resolve(a.Value)
+	switch v.Kind() {
+	case slog.KindAny:
+		switch v.Any().(type) {
+		case Label, Trace:
+			return true
+		}
+		return a.Key == "" && v.Any() == nil
+	case slog.KindGroup:
+		for _, a := range v.Group() {
+			if !h.isEmptyAttr(a) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isErrorAttr(a slog.Attr) bool {
+	if a.Key == "error" || a.Key == "err" {
+		return true
+	}
+	if a.Value.Kind() != slog.KindAny {
+		return false
+	}
+	_, ok := a.Value.Any().(error)
+	return ok
+}
+
+// addStringMap writes the map as a record with sorted keys, matching
+// json.Marshal without the reflection overhead.
+func (h *Handler) addStringMap(l LineWriter, key string, m map[string]string) {
+	l.StartRecord(key)
+	defer l.EndRecord()
+	for _, k := range sortedKeys(m) {
+		l.AddString(h.cleanString(k), h.cleanString(m[k]))
+	}
+}
+
+// addMap writes the map as a record with sorted keys, matching json.Marshal
+// without the reflection overhead for common value types. The keys and the
+// scalar values are written the same way as the attrs, e.g. with
+// NumbersAsStrings and SanitizeStrings applied.
+func (h *Handler) addMap(l LineWriter, key string, m map[string]any) error {
+	l.StartRecord(key)
+	defer l.EndRecord()
+	var err error
+	for _, k := range sortedKeys(m) {
+		err = errors.Join(err, h.addMapValue(l, h.cleanString(k), m[k]))
+	}
+	return err
+}
+
+func (h *Handler) addMapValue(l LineWriter, key string, v any) error {
+	switch v := v.(type) {
+	case string:
+		l.AddString(key, h.cleanString(v))
+		return nil
+	case bool:
+		l.AddBool(key, v)
+		return nil
+	case int:
+		h.addInt64(l, key, int64(v))
 		return nil
+	case int64:
+		h.addInt64(l, key, v)
+		return nil
+	case float64:
+		h.addFloat64(l, key, v)
+		return nil
+	case map[string]string:
+		if v != nil {
+			h.addStringMap(l, key, v)
+			return nil
+		}
+	case map[string]any:
+		if v != nil {
+			return h.addMap(l, key, v)
+		}
+	}
+	return addMarshal(l, key, v)
+}
+
+// addMarshal writes the value encoded with encoding/json. If the encoding
+// fails, a placeholder string with the error is written instead, so that the
+// rest of the entry is still written correctly.
+func addMarshal(l LineWriter, key string, v any) error {
+	var err error
+	if addErr := l.AddMarshal(key, marshalGuard{v: v, err: &err}); addErr != nil {
+		return addErr
+	}
+	return err
+}
+
+// marshalGuard replaces the encoding errors of the value with a placeholder
+// string, matching slog.JSONHandler, and stores the error.
+type marshalGuard struct {
+	v   any
+	err *error
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g marshalGuard) MarshalJSON() ([]byte, error) {
+	data, err := tokens.AppendMarshal(nil, g.v)
+	if err != nil {
+		*g.err = err
+		return tokens.AppendString(nil, "!ERROR:"+err.Error()), nil
+	}
+	return data, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (h *Handler) attrKey(key string) string {
+	if h.config.KeyTransform == nil {
+		return key
 	}
-	return l.AddMarshal(a.Key, val)
+	return h.config.KeyTransform(key)
 }
 
 const (
-	fieldMessage        = "message"
-	fieldTimestamp      = "timestamp"
-	fieldSeverity       = "severity"
-	fieldSourceLocation = "logging.googleapis.com/sourceLocation"
 	fieldSourceFile     = "file"
 	fieldSourceLine     = "line"
 	fieldSourceFunction = "function"
-	fieldTraceID        = "logging.googleapis.com/trace"
-	fieldTraceSpanID    = "logging.googleapis.com/spanId"
-	fieldTraceSampled   = "logging.googleapis.com/trace_sampled"
-	fieldLabels         = "logging.googleapis.com/labels"
-)
+	fieldCaller         = "caller"
+	fieldPayload        = "jsonPayload"
+	fieldAttrsTruncated = "attrs_truncated"
+	fieldLogger         = "logger"
+	fieldSeverityNumber = "severityNumber"
+	fieldRequestID      = "request_id"
+	fieldUptime         = "uptime_ms"
+	fieldGoroutine      = "goroutine"
+	fieldDeadline       = "deadline_ms"
+	fieldLevel          = "level"
+	fieldDurationNanos  = "nanos"
+	fieldDurationHuman  = "human"
 
-const (
-	severityError = 500
-	severityWarn  = 400
-	severityInfo  = 300
-	severityDebug = 200
+	fieldReceiveTimestamp = "receiveTimestamp"
+
+	fieldServiceContextService = "service"
+	fieldServiceContextVersion = "version"
+
+	fieldResourceType   = "type"
+	fieldResourceLabels = "labels"
+
+	fieldErrorMessage = "message"
+	fieldErrorType    = "type"
 )
 
+const typeReportedErrorEvent = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
 func cloneSlice[T any](slice []T, extraCap int) []T {
 	return append(make([]T, 0, len(slice)+extraCap), slice...)
 }
@@ -7,12 +7,22 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/baggagelabels"
 	"github.com/jussi-kalliokoski/slogdriver/internal/require"
 	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
 )
@@ -54,6 +64,35 @@ func TestHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("LevelFunc", func(t *testing.T) {
+		type Entry struct {
+			Message string `json:"message"`
+		}
+
+		ctx := context.Background()
+		var capture slogtest.Capture[Entry]
+		level := slog.LevelInfo
+		h := slogdriver.NewHandler(&capture, slogdriver.Config{
+			Level: slogdriver.LevelFunc(func() slog.Level { return level }),
+		})
+		logger, errs := slogtest.NewWithErrorHandler(h)
+		expected := []Entry{{"info"}, {"debug"}}
+
+		first := h.Level()
+		logger.DebugContext(ctx, "dropped")
+		logger.InfoContext(ctx, "info")
+		level = slog.LevelDebug
+		second := h.Level()
+		logger.DebugContext(ctx, "debug")
+		received := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, slog.LevelInfo, first)
+		require.Equal(t, slog.LevelDebug, second)
+		require.Equal(t, expected, received)
+	})
+
 	t.Run("message", func(t *testing.T) {
 		type Entry struct {
 			Message string `json:"message"`
@@ -72,221 +111,2537 @@ func TestHandler(t *testing.T) {
 		require.Equal(t, "world", entries[1].Message)
 	})
 
-	t.Run("severity", func(t *testing.T) {
+	t.Run("empty message", func(t *testing.T) {
 		tests := []struct {
 			name     string
-			level    slog.Level
-			expected int
+			config   slogdriver.Config
+			message  string
+			expected *string
 		}{
-			{"debug", slog.LevelDebug, 200},
-			{"info", slog.LevelInfo, 300},
-			{"warn", slog.LevelWarn, 400},
-			{"error", slog.LevelError, 500},
-			{"below debug", slog.LevelDebug - 1, 200},
-			{"below info", slog.LevelInfo - 1, 200},
-			{"below warn", slog.LevelWarn - 1, 300},
-			{"below error", slog.LevelError - 1, 400},
-			{"above error", slog.LevelError + 1, 500},
+			{"default", slogdriver.Config{}, "", vptr("")},
+			{"omitted", slogdriver.Config{OmitEmptyMessage: true}, "", nil},
+			{"non-empty", slogdriver.Config{OmitEmptyMessage: true}, "hello", vptr("hello")},
 		}
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
 				type Entry struct {
-					Severity int `json:"severity"`
+					Message *string `json:"message"`
 				}
+
 				ctx := context.Background()
 				var capture slogtest.Capture[Entry]
-				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
-					Level: slog.Level(-1e6),
-				}))
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
 
-				logger.LogAttrs(ctx, tt.level, "level")
+				logger.LogAttrs(ctx, slog.LevelInfo, tt.message)
 				entries := capture.Entries()
+				received := entries[0].Message
 				err := errs.Err()
 
 				require.NoError(t, err)
-				require.Equal(t, tt.expected, entries[0].Severity)
+				require.Equal(t, tt.expected, received)
 			})
 		}
 	})
 
-	t.Run("source location", func(t *testing.T) {
+	t.Run("timestamp", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			expected bool
+		}{
+			{"default", slogdriver.Config{}, true},
+			{"omitted", slogdriver.Config{OmitTimestamp: true}, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Timestamp *string `json:"timestamp"`
+				}
+
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "timestamp")
+				entries := capture.Entries()
+				received := entries[0].Timestamp != nil
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("timestamp UTC", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			expected string
+		}{
+			{"default", slogdriver.Config{}, "2023-06-15T21:24:13.5+02:00"},
+			{"UTC", slogdriver.Config{TimestampUTC: true}, "2023-06-15T19:24:13.5Z"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Timestamp string `json:"timestamp"`
+				}
+
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				zone := time.FixedZone("EET", 2*60*60)
+				r := slog.NewRecord(time.Date(2023, 6, 15, 21, 24, 13, 500000000, zone), slog.LevelInfo, "timestamp", 0)
+
+				err := logger.Handler().Handle(ctx, r)
+				entries := capture.Entries()
+				received := entries[0].Timestamp
+
+				require.NoError(t, err)
+				require.NoError(t, errs.Err())
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("receive timestamp", func(t *testing.T) {
 		type Entry struct {
-			SourceLocation struct {
-				File     string `json:"file"`
-				Line     int    `json:"line"`
-				Function string `json:"function"`
-			} `json:"logging.googleapis.com/sourceLocation"`
+			Timestamp        *time.Time `json:"timestamp"`
+			ReceiveTimestamp *time.Time `json:"receiveTimestamp"`
 		}
 
+		ctx := context.Background()
 		var capture slogtest.Capture[Entry]
-		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+		now := time.Date(2023, 6, 15, 19, 24, 13, 0, time.UTC)
+		h := slogdriver.NewHandler(&capture, slogdriver.Config{
+			EmitReceiveTimestamp: true,
+			Now: func() time.Time {
+				now = now.Add(1500 * time.Millisecond)
+				return now
+			},
+		})
+		recordTime := now
 
-		logger.Info("hello")
-		fs := runtime.CallersFrames([]uintptr{getPC()})
-		expected, _ := fs.Next()
+		err1 := h.Handle(ctx, slog.NewRecord(recordTime, slog.LevelInfo, "first", 0))
+		err2 := h.Handle(ctx, slog.NewRecord(recordTime, slog.LevelInfo, "second", 0))
+		entries := capture.Entries()
+
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.Equal(t, recordTime, *entries[0].Timestamp)
+		require.Equal(t, recordTime.Add(1500*time.Millisecond), *entries[0].ReceiveTimestamp)
+		require.Equal(t, recordTime, *entries[1].Timestamp)
+		require.Equal(t, recordTime.Add(3*time.Second), *entries[1].ReceiveTimestamp)
+	})
+
+	t.Run("goroutine ID", func(t *testing.T) {
+		type Entry struct {
+			Goroutine *uint64 `json:"goroutine"`
+		}
+
+		t.Run("disabled", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+
+			logger.InfoContext(ctx, "goroutine")
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, true, entries[0].Goroutine == nil)
+		})
+
+		t.Run("enabled", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				IncludeGoroutineID: true,
+			}))
+
+			logger.InfoContext(ctx, "first")
+			logger.InfoContext(ctx, "second")
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				logger.InfoContext(ctx, "other")
+			}()
+			<-done
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, true, *entries[0].Goroutine > 0)
+			require.Equal(t, *entries[0].Goroutine, *entries[1].Goroutine)
+			require.Equal(t, true, *entries[2].Goroutine > 0)
+			require.Equal(t, true, *entries[0].Goroutine != *entries[2].Goroutine)
+		})
+	})
+
+	t.Run("startup record", func(t *testing.T) {
+		ctx := context.Background()
+		var capture slogtest.Capture[map[string]any]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			EmitStartupRecord: true,
+			ServiceContext:    slogdriver.ServiceContext{Service: "service", Version: "v1.2.3"},
+		}))
+
+		logger.InfoContext(ctx, "first")
+		logger.With(slog.String("foo", "bar")).InfoContext(ctx, "second")
 		entries := capture.Entries()
-		received := entries[0].SourceLocation
 		err := errs.Err()
 
 		require.NoError(t, err)
-		require.Equal(t, expected.File, received.File)
-		require.Equal(t, expected.Line-1, received.Line)
-		require.Equal(t, expected.Function, received.Function)
+		require.Equal(t, 3, len(entries))
+		require.Equal[any](t, "startup", entries[0]["message"])
+		require.Equal(t, false, hasKey(entries[0], "severity"))
+		startup := entries[0]["startup"].(map[string]any)
+		require.Equal[any](t, float64(os.Getpid()), startup["pid"])
+		require.Equal[any](t, "v1.2.3", startup["version"])
+		startTime, parseErr := time.Parse(time.RFC3339Nano, startup["startTime"].(string))
+		require.NoError(t, parseErr)
+		require.Equal(t, false, startTime.After(time.Now()))
+		require.Equal[any](t, "first", entries[1]["message"])
+		require.Equal[any](t, "second", entries[2]["message"])
+		require.Equal(t, false, hasKey(entries[1], "startup"))
 	})
 
-	t.Run("trace", func(t *testing.T) {
-		type TraceInfo struct {
-			TraceID      *string `json:"logging.googleapis.com/trace"`
-			SpanID       *string `json:"logging.googleapis.com/spanId"`
-			TraceSampled *bool   `json:"logging.googleapis.com/trace_sampled"`
+	t.Run("deadline", func(t *testing.T) {
+		type Entry struct {
+			Deadline *int64 `json:"deadline_ms"`
 		}
 
+		now := time.Date(2023, 6, 15, 19, 24, 13, 0, time.UTC)
 		tests := []struct {
 			name     string
 			config   slogdriver.Config
-			ctx      context.Context
-			expected TraceInfo
+			deadline time.Time
+			expected *int64
 		}{
-			{
-				"no trace info",
-				slogdriver.Config{},
-				context.Background(),
-				TraceInfo{},
-			},
-			{
-				"span ID unavailable",
-				slogdriver.Config{
-					ProjectID: "jectpro",
-				},
-				slogdriver.Trace{
-					ID: "abc",
-				}.Context(context.Background()),
-				TraceInfo{
-					TraceID:      vptr("projects/jectpro/traces/abc"),
-					TraceSampled: vptr(false),
-				},
-			},
-			{
-				"sampled",
-				slogdriver.Config{
-					ProjectID: "ectproj",
-				},
-				slogdriver.Trace{
-					ID:      "bcd",
-					Sampled: true,
-				}.Context(context.Background()),
-				TraceInfo{
-					TraceID:      vptr("projects/ectproj/traces/bcd"),
-					TraceSampled: vptr(true),
-				},
-			},
-			{
-				"span ID",
-				slogdriver.Config{
-					ProjectID: "ctproje",
-				},
-				slogdriver.Trace{
-					ID:     "cde",
-					SpanID: "foobar",
-				}.Context(context.Background()),
-				TraceInfo{
-					TraceID:      vptr("projects/ctproje/traces/cde"),
-					SpanID:       vptr("foobar"),
-					TraceSampled: vptr(false),
-				},
-			},
+			{"disabled", slogdriver.Config{}, now.Add(time.Second), nil},
+			{"no deadline", slogdriver.Config{IncludeDeadline: true}, time.Time{}, nil},
+			{"remaining", slogdriver.Config{IncludeDeadline: true}, now.Add(1500 * time.Millisecond), vptr[int64](1500)},
+			{"exceeded", slogdriver.Config{IncludeDeadline: true}, now.Add(-250 * time.Millisecond), vptr[int64](-250)},
 		}
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-
-				ctx := tt.ctx
-				var capture slogtest.Capture[TraceInfo]
+				ctx := context.Background()
+				if !tt.deadline.IsZero() {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithDeadline(ctx, tt.deadline)
+					defer cancel()
+				}
+				var capture slogtest.Capture[Entry]
+				tt.config.Now = func() time.Time { return now }
 				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
 
-				logger.InfoContext(ctx, "trace")
+				logger.InfoContext(ctx, "deadline")
 				entries := capture.Entries()
-				received := entries[0]
 				err := errs.Err()
 
 				require.NoError(t, err)
-				require.Equal(t, tt.expected, received)
+				require.Equal(t, tt.expected == nil, entries[0].Deadline == nil)
+				if tt.expected != nil {
+					require.Equal(t, *tt.expected, *entries[0].Deadline)
+				}
 			})
 		}
+
+		t.Run("timeout", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				IncludeDeadline: true,
+			}))
+
+			logger.InfoContext(ctx, "deadline")
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, true, *entries[0].Deadline > 0)
+			require.Equal(t, true, *entries[0].Deadline <= time.Minute.Milliseconds())
+		})
 	})
 
-	t.Run("labels", func(t *testing.T) {
+	t.Run("uptime", func(t *testing.T) {
 		type Entry struct {
-			Labels map[string]string `json:"logging.googleapis.com/labels"`
+			Uptime *int64 `json:"uptime_ms"`
 		}
 
 		tests := []struct {
 			name     string
-			ctx      context.Context
-			expected map[string]string
+			config   slogdriver.Config
+			expected []*int64
 		}{
-			{
-				"no labels",
-				context.Background(),
-				nil,
-			},
-			{
-				"empty labels",
-				slogdriver.AddLabels(
-					context.Background(),
-				),
-				nil,
-			},
-			{
-				"some labels",
-				slogdriver.AddLabels(
-					context.Background(),
-					slogdriver.NewLabel("foo", "bar"),
-					slogdriver.NewLabel("voo", "doo"),
-				),
-				map[string]string{
-					"foo": "bar",
-					"voo": "doo",
-				},
-			},
-			{
-				"extended labels",
-				slogdriver.AddLabels(
-					slogdriver.AddLabels(
-						context.Background(),
-						slogdriver.NewLabel("first", "1"),
-						slogdriver.NewLabel("second", "2"),
-					),
-					slogdriver.NewLabel("second", "changed"),
-					slogdriver.NewLabel("third", "3"),
-				),
-				map[string]string{
-					"first":  "1",
-					"second": "changed",
-					"third":  "3",
-				},
-			},
+			{"disabled", slogdriver.Config{}, []*int64{nil, nil}},
+			{"enabled", slogdriver.Config{IncludeUptime: true}, []*int64{vptr[int64](250), vptr[int64](1500)}},
 		}
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-
-				ctx := tt.ctx
+				ctx := context.Background()
 				var capture slogtest.Capture[Entry]
-				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+				now := time.Date(2023, 6, 15, 19, 24, 13, 0, time.UTC)
+				tt.config.Now = func() time.Time { return now }
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
 
-				logger.InfoContext(ctx, "labels")
+				now = now.Add(250 * time.Millisecond)
+				logger.InfoContext(ctx, "first")
+				now = now.Add(1250 * time.Millisecond)
+				logger.InfoContext(ctx, "second")
 				entries := capture.Entries()
-				received := entries[0].Labels
 				err := errs.Err()
 
 				require.NoError(t, err)
-				require.Equal(t, tt.expected, received)
+				for i, expected := range tt.expected {
+					require.Equal(t, expected == nil, entries[i].Uptime == nil)
+					if expected != nil {
+						require.Equal(t, *expected, *entries[i].Uptime)
+					}
+				}
 			})
 		}
 	})
 
+	t.Run("severity", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			level    slog.Level
+			expected int
+		}{
+			{"debug", slog.LevelDebug, 200},
+			{"info", slog.LevelInfo, 300},
+			{"warn", slog.LevelWarn, 400},
+			{"error", slog.LevelError, 500},
+			{"below debug", slog.LevelDebug - 1, 200},
+			{"below info", slog.LevelInfo - 1, 200},
+			{"below warn", slog.LevelWarn - 1, 300},
+			{"below error", slog.LevelError - 1, 400},
+			{"above error", slog.LevelError + 1, 500},
+			{"critical", slogdriver.LevelCritical, 600},
+			{"below critical", slogdriver.LevelCritical - 1, 500},
+			{"above critical", slogdriver.LevelCritical + 1, 600},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity int `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					Level: slog.Level(-1e6),
+				}))
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+	})
+
+	t.Run("severity attr", func(t *testing.T) {
+		tests := []struct {
+			name             string
+			config           slogdriver.Config
+			attrs            []slog.Attr
+			expectedSeverity string
+			expectedError    bool
+			expectedPayload  string
+		}{
+			{"none", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, nil, `"WARNING"`, false, ""},
+			{"critical", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "CRITICAL")}, `"CRITICAL"`, false, ""},
+			{"alert", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "ALERT")}, `"ALERT"`, false, ""},
+			{"emergency", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "EMERGENCY")}, `"EMERGENCY"`, false, ""},
+			{"default", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "DEFAULT")}, `"DEFAULT"`, false, ""},
+			{"lower", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "DEBUG")}, `"DEBUG"`, false, ""},
+			{"last wins", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "ALERT"), slog.String("severity", "NOTICE")}, `"NOTICE"`, false, ""},
+			{"numeric", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatNumeric}, []slog.Attr{slog.String("severity", "ALERT")}, `700`, false, ""},
+			{"unknown", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "SEVERE")}, `"WARNING"`, false, `"severity":"SEVERE",`},
+			{"lowercase", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString}, []slog.Attr{slog.String("severity", "error")}, `"WARNING"`, false, `"severity":"error",`},
+			{"unknown strict", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString, StrictSeverity: true}, []slog.Attr{slog.String("severity", "SEVERE")}, `"WARNING"`, true, `"severity":"SEVERE",`},
+			{"dedup", slogdriver.Config{SeverityFormat: slogdriver.SeverityFormatString, DedupAttrs: slogdriver.DedupLastWins}, []slog.Attr{slog.String("severity", "ALERT")}, `"ALERT"`, false, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, slog.LevelWarn, "severity", append(tt.attrs, slog.String("Other", "value"))...)
+				entries := capture.Entries()
+				received := entries[0]
+				raw := string(capture.Raw())
+				err := errs.Err()
+
+				require.Equal(t, tt.expectedError, err != nil)
+				require.Equal(t, true, strings.Contains(raw, `"severity":`+tt.expectedSeverity))
+				require.Equal(t, true, strings.Contains(raw, tt.expectedPayload+`"Other":"value"`))
+				require.Equal(t, "value", received["Other"])
+				if tt.expectedPayload == "" {
+					require.Equal(t, 1, strings.Count(raw, `"severity":`))
+				}
+			})
+		}
+
+		t.Run("in group", func(t *testing.T) {
+			type Entry struct {
+				Severity string `json:"severity"`
+				G        struct {
+					Severity string `json:"severity"`
+				} `json:"g"`
+			}
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				SeverityFormat: slogdriver.SeverityFormatString,
+			}))
+
+			logger.WithGroup("g").WarnContext(ctx, "severity", slog.String("severity", "ALERT"))
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, "WARNING", entries[0].Severity)
+			require.Equal(t, "ALERT", entries[0].G.Severity)
+		})
+	})
+
+	t.Run("level name", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			level    slog.Level
+			expected *string
+		}{
+			{"disabled", slogdriver.Config{}, slog.LevelInfo, nil},
+			{"info", slogdriver.Config{IncludeLevelName: true}, slog.LevelInfo, vptr("INFO")},
+			{"custom", slogdriver.Config{IncludeLevelName: true}, slog.LevelDebug + 2, vptr("DEBUG+2")},
+			{"critical", slogdriver.Config{IncludeLevelName: true}, slogdriver.LevelCritical, vptr("CRITICAL")},
+			{"above critical", slogdriver.Config{IncludeLevelName: true}, slogdriver.LevelCritical + 1, vptr("ERROR+5")},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity int     `json:"severity"`
+					Level    *string `json:"level"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				tt.config.Level = slog.LevelDebug
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				received := entries[0].Level
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected == nil, received == nil)
+				if tt.expected != nil {
+					require.Equal(t, *tt.expected, *received)
+				}
+			})
+		}
+	})
+
+	t.Run("numeric severity", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			level          slog.Level
+			severity       string
+			severityNumber int
+		}{
+			{"debug", slog.LevelDebug, "DEBUG", 100},
+			{"info", slog.LevelInfo, "INFO", 200},
+			{"notice", slog.LevelInfo + 2, "NOTICE", 300},
+			{"warn", slog.LevelWarn, "WARNING", 400},
+			{"error", slog.LevelError, "ERROR", 500},
+			{"critical", slogdriver.LevelCritical, "CRITICAL", 600},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity       string `json:"severity"`
+					SeverityNumber int    `json:"severityNumber"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					Level:               slog.LevelDebug,
+					SeverityFormat:      slogdriver.SeverityFormatString,
+					NoticeLevel:         slog.LevelInfo + 2,
+					EmitNumericSeverity: true,
+				}))
+				expected := Entry{tt.severity, tt.severityNumber}
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, expected, entries[0])
+			})
+		}
+	})
+
+	t.Run("lowercase severity", func(t *testing.T) {
+		type Entry struct {
+			Severity string `json:"severity"`
+		}
+		tests := []struct {
+			name     string
+			level    slog.Level
+			expected string
+		}{
+			{"debug", slog.LevelDebug, "debug"},
+			{"info", slog.LevelInfo, "info"},
+			{"notice", slog.LevelInfo + 2, "notice"},
+			{"warn", slog.LevelWarn, "warning"},
+			{"error", slog.LevelError, "error"},
+			{"critical", slogdriver.LevelCritical, "critical"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					Level:             slog.LevelDebug,
+					SeverityFormat:    slogdriver.SeverityFormatString,
+					NoticeLevel:       slog.LevelInfo + 2,
+					LowercaseSeverity: true,
+				}))
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+
+		t.Run("severity attr", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				SeverityFormat:    slogdriver.SeverityFormatString,
+				LowercaseSeverity: true,
+			}))
+
+			logger.InfoContext(ctx, "level", slog.String(slogdriver.SeverityAttrKey, "ALERT"))
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, "alert", entries[0].Severity)
+		})
+	})
+
+	t.Run("message formatter", func(t *testing.T) {
+		type Entry struct {
+			Message   string `json:"message"`
+			RequestID string
+		}
+
+		ctx := context.Background()
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			MessageFormatter: func(msg string, r *slog.Record) string {
+				r.Attrs(func(a slog.Attr) bool {
+					if a.Key == "RequestID" {
+						msg += " (request " + a.Value.String() + ")"
+						return false
+					}
+					return true
+				})
+				return msg
+			},
+		}))
+		expected := []Entry{
+			{"handled (request abc)", "abc"},
+			{"no request", ""},
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "handled", slog.String("RequestID", "abc"))
+		logger.LogAttrs(ctx, slog.LevelInfo, "no request")
+		received := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("level var", func(t *testing.T) {
+		type Entry struct {
+			Message string `json:"message"`
+		}
+
+		ctx := context.Background()
+		var capture slogtest.Capture[Entry]
+		var level slog.LevelVar
+		h := slogdriver.NewHandler(&capture, slogdriver.Config{Level: &level})
+		logger, errs := slogtest.NewWithErrorHandler(h.WithAttrs([]slog.Attr{slog.String("foo", "bar")}))
+		expected := []Entry{{"info 1"}, {"debug 2"}, {"warn 3"}}
+
+		logger.DebugContext(ctx, "debug 1")
+		logger.InfoContext(ctx, "info 1")
+		level.Set(slog.LevelDebug)
+		logger.DebugContext(ctx, "debug 2")
+		setErr := h.SetLevel(slog.LevelWarn)
+		logger.InfoContext(ctx, "info 3")
+		logger.WarnContext(ctx, "warn 3")
+		received := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.NoError(t, setErr)
+		require.Equal(t, slog.LevelWarn, level.Level())
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("level not settable", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			level slog.Leveler
+		}{
+			{"unset", nil},
+			{"constant", slog.LevelWarn},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var capture slogtest.Capture[map[string]any]
+				h := slogdriver.NewHandler(&capture, slogdriver.Config{Level: tt.level})
+
+				err := h.SetLevel(slog.LevelDebug)
+
+				require.Equal(t, slogdriver.ErrLevelNotSettable, err)
+				require.Equal(t, false, h.Enabled(context.Background(), slog.LevelDebug))
+			})
+		}
+	})
+
+	t.Run("sanitize strings", func(t *testing.T) {
+		type Entry struct {
+			Message string `json:"message"`
+			Value   string
+		}
+
+		tests := []struct {
+			name     string
+			sanitize bool
+			expected Entry
+		}{
+			{"disabled", false, Entry{"ding\x07 dong", "a\x1b[31mb\tc\nd\x7f"}},
+			{"enabled", true, Entry{"ding dong", "a[31mb\tc\nd"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					SanitizeStrings: tt.sanitize,
+				}))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "ding\x07 dong", slog.String("Value", "a\x1b[31mb\tc\nd\x7f"))
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("invalid UTF-8", func(t *testing.T) {
+		type Entry struct {
+			Message string `json:"message"`
+			Value   string
+		}
+
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			expected Entry
+		}{
+			{"disabled", slogdriver.Config{}, Entry{"ding\ufffd\ufffd dong", "a\ufffd\x07b"}},
+			{"enabled", slogdriver.Config{FixInvalidUTF8: true}, Entry{"ding\ufffd dong", "a\ufffd\x07b"}},
+			{"sanitized", slogdriver.Config{FixInvalidUTF8: true, SanitizeStrings: true}, Entry{"ding\ufffd dong", "a\ufffdb"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "ding\xff\xfe dong", slog.String("Value", "a\xff\x07b"))
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, true, utf8.Valid(capture.Raw()))
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("severity clamping", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			level    slog.Level
+			attrs    []slog.Attr
+			expected string
+		}{
+			{"unclamped debug", slogdriver.Config{}, slog.LevelDebug, nil, "DEBUG"},
+			{"unclamped critical", slogdriver.Config{}, slogdriver.LevelCritical + 8, nil, "CRITICAL"},
+			{"debug up to info", slogdriver.Config{MinSeverity: slog.LevelInfo}, slog.LevelDebug, nil, "INFO"},
+			{"warn within range", slogdriver.Config{MinSeverity: slog.LevelInfo, MaxSeverity: slog.LevelError}, slog.LevelWarn, nil, "WARNING"},
+			{"emergency down to error", slogdriver.Config{MaxSeverity: slog.LevelError}, slogdriver.LevelCritical + 8, nil, "ERROR"},
+			{"attr down to error", slogdriver.Config{MaxSeverity: slog.LevelError}, slog.LevelInfo, []slog.Attr{slog.String(slogdriver.SeverityAttrKey, "EMERGENCY")}, "ERROR"},
+			{"attr up to info", slogdriver.Config{MinSeverity: slog.LevelInfo}, slog.LevelWarn, []slog.Attr{slog.String(slogdriver.SeverityAttrKey, "DEBUG")}, "INFO"},
+			{"default attr up to info", slogdriver.Config{MinSeverity: slog.LevelInfo}, slog.LevelWarn, []slog.Attr{slog.String(slogdriver.SeverityAttrKey, "DEFAULT")}, "INFO"},
+			{"attr within range", slogdriver.Config{MinSeverity: slog.LevelInfo, MaxSeverity: slog.LevelError}, slog.LevelInfo, []slog.Attr{slog.String(slogdriver.SeverityAttrKey, "NOTICE")}, "NOTICE"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity string `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				config := tt.config
+				config.Level = slog.LevelDebug
+				config.SeverityFormat = slogdriver.SeverityFormatString
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, config))
+
+				logger.LogAttrs(ctx, tt.level, "level", tt.attrs...)
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+	})
+
+	t.Run("default level", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			defaultLevel slog.Leveler
+			level        slog.Level
+			expected     int
+			enabled      bool
+		}{
+			{"unset", nil, 0, 300, true},
+			{"warn", slog.LevelWarn, 0, 400, true},
+			{"debug", slog.LevelDebug, 0, 200, false},
+			{"explicit level", slog.LevelWarn, slog.LevelError, 500, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity int `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				h := slogdriver.NewHandler(&capture, slogdriver.Config{
+					DefaultLevel: tt.defaultLevel,
+				})
+
+				enabled := h.Enabled(ctx, tt.level)
+				err := h.Handle(ctx, slog.NewRecord(time.Now(), tt.level, "level", 0))
+				entries := capture.Entries()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.enabled, enabled)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+	})
+
+	t.Run("escalate on error", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			escalate  bool
+			level     slog.Level
+			withAttrs []slog.Attr
+			attrs     []slog.Attr
+			expected  []int
+		}{
+			{"disabled", false, slog.LevelInfo, nil, []slog.Attr{slog.Any("cause", errors.New("failed"))}, []int{300}},
+			{"no error", true, slog.LevelInfo, nil, []slog.Attr{slog.String("foo", "bar")}, []int{300}},
+			{"error value", true, slog.LevelInfo, nil, []slog.Attr{slog.Any("cause", errors.New("failed"))}, []int{500}},
+			{"error key", true, slog.LevelWarn, nil, []slog.Attr{slog.String("error", "failed")}, []int{500}},
+			{"err key", true, slog.LevelInfo, nil, []slog.Attr{slog.String("err", "failed")}, []int{500}},
+			{"WithAttrs", true, slog.LevelInfo, []slog.Attr{slog.Any("cause", errors.New("failed"))}, nil, []int{500}},
+			{"below enabled level", true, slog.LevelDebug, nil, []slog.Attr{slog.Any("cause", errors.New("failed"))}, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity int `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				h := slogdriver.NewHandler(&capture, slogdriver.Config{
+					EscalateOnError: tt.escalate,
+				}).WithAttrs(tt.withAttrs)
+				logger, errs := slogtest.NewWithErrorHandler(h)
+
+				logger.LogAttrs(ctx, tt.level, "escalate", tt.attrs...)
+				entries := capture.Entries()
+				var received []int
+				for _, entry := range entries {
+					received = append(received, entry.Severity)
+				}
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("severity format", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			format   slogdriver.SeverityFormat
+			level    slog.Level
+			expected any
+		}{
+			{"numeric debug", slogdriver.SeverityFormatNumeric, slog.LevelDebug, float64(200)},
+			{"numeric info", slogdriver.SeverityFormatNumeric, slog.LevelInfo, float64(300)},
+			{"numeric warn", slogdriver.SeverityFormatNumeric, slog.LevelWarn, float64(400)},
+			{"numeric error", slogdriver.SeverityFormatNumeric, slog.LevelError, float64(500)},
+			{"enum debug", slogdriver.SeverityFormatEnum, slog.LevelDebug, float64(100)},
+			{"enum info", slogdriver.SeverityFormatEnum, slog.LevelInfo, float64(200)},
+			{"enum warn", slogdriver.SeverityFormatEnum, slog.LevelWarn, float64(400)},
+			{"enum error", slogdriver.SeverityFormatEnum, slog.LevelError, float64(500)},
+			{"enum critical", slogdriver.SeverityFormatEnum, slogdriver.LevelCritical, float64(600)},
+			{"string debug", slogdriver.SeverityFormatString, slog.LevelDebug, "DEBUG"},
+			{"string info", slogdriver.SeverityFormatString, slog.LevelInfo, "INFO"},
+			{"string warn", slogdriver.SeverityFormatString, slog.LevelWarn, "WARNING"},
+			{"string error", slogdriver.SeverityFormatString, slog.LevelError, "ERROR"},
+			{"string critical", slogdriver.SeverityFormatString, slogdriver.LevelCritical, "CRITICAL"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity any `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					Level:          slog.LevelDebug,
+					SeverityFormat: tt.format,
+				}))
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+	})
+
+	t.Run("notice", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			noticeLevel slog.Leveler
+			level       slog.Level
+			expected    string
+		}{
+			{"disabled", nil, slog.LevelInfo + 2, "INFO"},
+			{"info", slog.LevelInfo + 2, slog.LevelInfo, "INFO"},
+			{"below notice", slog.LevelInfo + 2, slog.LevelInfo + 1, "INFO"},
+			{"notice", slog.LevelInfo + 2, slog.LevelInfo + 2, "NOTICE"},
+			{"below warn", slog.LevelInfo + 2, slog.LevelWarn - 1, "NOTICE"},
+			{"warn", slog.LevelInfo + 2, slog.LevelWarn, "WARNING"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Severity string `json:"severity"`
+				}
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					SeverityFormat: slogdriver.SeverityFormatString,
+					NoticeLevel:    tt.noticeLevel,
+				}))
+
+				logger.LogAttrs(ctx, tt.level, "level")
+				entries := capture.Entries()
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, entries[0].Severity)
+			})
+		}
+
+		t.Run("enum", func(t *testing.T) {
+			type Entry struct {
+				Severity int `json:"severity"`
+			}
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				Level:          slog.LevelDebug,
+				SeverityFormat: slogdriver.SeverityFormatEnum,
+				NoticeLevel:    slog.LevelInfo + 2,
+			}))
+
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Log(ctx, slog.LevelInfo+2, "notice")
+			entries := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, []Entry{{100}, {200}, {300}}, entries)
+		})
+	})
+
+	t.Run("custom keys", func(t *testing.T) {
+		type Entry struct {
+			Log   string `json:"log"`
+			Level int    `json:"level"`
+		}
+
+		ctx := context.Background()
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			MessageKey:  "log",
+			SeverityKey: "level",
+		}))
+		expected := Entry{"hello", 400}
+
+		logger.LogAttrs(ctx, slog.LevelWarn, "hello")
+		entries := capture.Entries()
+		received := entries[0]
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("source location", func(t *testing.T) {
+		type Entry struct {
+			SourceLocation struct {
+				File     string `json:"file"`
+				Line     int    `json:"line"`
+				Function string `json:"function"`
+			} `json:"logging.googleapis.com/sourceLocation"`
+		}
+
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+
+		logger.Info("hello")
+		fs := runtime.CallersFrames([]uintptr{getPC()})
+		expected, _ := fs.Next()
+		entries := capture.Entries()
+		received := entries[0].SourceLocation
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, expected.File, received.File)
+		require.Equal(t, expected.Line-1, received.Line)
+		require.Equal(t, expected.Function, received.Function)
+	})
+
+	t.Run("source location skip prefixes", func(t *testing.T) {
+		type Entry struct {
+			SourceLocation *struct {
+				Function string `json:"function"`
+			} `json:"logging.googleapis.com/sourceLocation"`
+		}
+
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			SourceSkipPrefixes: []string{
+				"example.com/unrelated.",
+				"github.com/jussi-kalliokoski/slogdriver_test.logFromLibrary",
+			},
+		}))
+
+		logFromLibrary(logger)
+		logger.Info("own")
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, true, entries[0].SourceLocation == nil)
+		require.Equal(t, true, entries[1].SourceLocation != nil)
+		require.Equal(t, true, strings.HasPrefix(entries[1].SourceLocation.Function, "github.com/jussi-kalliokoski/slogdriver_test.TestHandler"))
+	})
+
+	t.Run("short source function", func(t *testing.T) {
+		type Entry struct {
+			SourceLocation struct {
+				Function string `json:"function"`
+			} `json:"logging.googleapis.com/sourceLocation"`
+		}
+
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			ShortSourceFunction: true,
+		}))
+
+		logFromLibrary(logger)
+		(&libraryLogger{logger}).Log()
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, "logFromLibrary", entries[0].SourceLocation.Function)
+		require.Equal(t, "libraryLogger.Log", entries[1].SourceLocation.Function)
+	})
+
+	t.Run("omit source function", func(t *testing.T) {
+		ctx := context.Background()
+		var capture slogtest.Capture[map[string]any]
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			OmitSourceFunction: true,
+		}))
+
+		logger.InfoContext(ctx, "source")
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		source := entries[0]["logging.googleapis.com/sourceLocation"].(map[string]any)
+		require.Equal(t, false, hasKey(source, "function"))
+		require.Equal(t, true, strings.HasSuffix(source["file"].(string), "handler_test.go"))
+		require.Equal(t, true, source["line"].(float64) > 0)
+	})
+
+	t.Run("source style", func(t *testing.T) {
+		type Entry struct {
+			SourceLocation *struct {
+				File string `json:"file"`
+			} `json:"logging.googleapis.com/sourceLocation"`
+			Caller *string `json:"caller"`
+		}
+
+		pc := getPC()
+		fs := runtime.CallersFrames([]uintptr{pc})
+		f, _ := fs.Next()
+		caller := fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line)
+
+		tests := []struct {
+			name           string
+			style          slogdriver.SourceStyle
+			pc             uintptr
+			expectedObject bool
+			expectedCaller *string
+		}{
+			{"object", slogdriver.SourceStyleObject, pc, true, nil},
+			{"string", slogdriver.SourceStyleString, pc, false, &caller},
+			{"object zero PC", slogdriver.SourceStyleObject, 0, false, nil},
+			{"string zero PC", slogdriver.SourceStyleString, 0, false, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					SourceStyle: tt.style,
+				}))
+				r := slog.NewRecord(time.Now(), slog.LevelInfo, "source", tt.pc)
+
+				err := logger.Handler().Handle(ctx, r)
+				entries := capture.Entries()
+				received := entries[0]
+
+				require.NoError(t, err)
+				require.NoError(t, errs.Err())
+				require.Equal(t, tt.expectedObject, received.SourceLocation != nil)
+				require.Equal(t, tt.expectedCaller, received.Caller)
+			})
+		}
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		type TraceInfo struct {
+			TraceID      *string `json:"logging.googleapis.com/trace"`
+			SpanID       *string `json:"logging.googleapis.com/spanId"`
+			TraceSampled *bool   `json:"logging.googleapis.com/trace_sampled"`
+		}
+
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			ctx      context.Context
+			expected TraceInfo
+		}{
+			{
+				"no trace info",
+				slogdriver.Config{},
+				context.Background(),
+				TraceInfo{},
+			},
+			{
+				"span ID unavailable",
+				slogdriver.Config{
+					ProjectID: "jectpro",
+				},
+				slogdriver.Trace{
+					ID: "abc",
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/jectpro/traces/abc"),
+					TraceSampled: vptr(false),
+				},
+			},
+			{
+				"sampled",
+				slogdriver.Config{
+					ProjectID: "ectproj",
+				},
+				slogdriver.Trace{
+					ID:      "bcd",
+					Sampled: true,
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/ectproj/traces/bcd"),
+					TraceSampled: vptr(true),
+				},
+			},
+			{
+				"span ID",
+				slogdriver.Config{
+					ProjectID: "ctproje",
+				},
+				slogdriver.Trace{
+					ID:     "cde",
+					SpanID: "foobar",
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/ctproje/traces/cde"),
+					SpanID:       vptr("foobar"),
+					TraceSampled: vptr(false),
+				},
+			},
+			{
+				"span without trace",
+				slogdriver.Config{
+					ProjectID: "rojectp",
+				},
+				slogdriver.Trace{
+					SpanID: "foobar",
+				}.Context(context.Background()),
+				TraceInfo{},
+			},
+			{
+				"span without trace emitted",
+				slogdriver.Config{
+					ProjectID:            "ojectpr",
+					EmitSpanWithoutTrace: true,
+				},
+				slogdriver.Trace{
+					SpanID:  "foobar",
+					Sampled: true,
+				}.Context(context.Background()),
+				TraceInfo{
+					SpanID: vptr("foobar"),
+				},
+			},
+			{
+				"unsampled flag omitted",
+				slogdriver.Config{
+					ProjectID:         "tprojec",
+					OmitUnsampledFlag: true,
+				},
+				slogdriver.Trace{
+					ID:     "def",
+					SpanID: "foobar",
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID: vptr("projects/tprojec/traces/def"),
+					SpanID:  vptr("foobar"),
+				},
+			},
+			{
+				"sampled flag kept",
+				slogdriver.Config{
+					ProjectID:         "projectp",
+					OmitUnsampledFlag: true,
+				},
+				slogdriver.Trace{
+					ID:      "efg",
+					SpanID:  "foobar",
+					Sampled: true,
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/projectp/traces/efg"),
+					SpanID:       vptr("foobar"),
+					TraceSampled: vptr(true),
+				},
+			},
+			{
+				"forced sampling",
+				slogdriver.Config{
+					ProjectID:         "jectpro",
+					OmitUnsampledFlag: true,
+				},
+				slogdriver.ForceSample(slogdriver.Trace{
+					ID:     "fgh",
+					SpanID: "foobar",
+				}.Context(context.Background())),
+				TraceInfo{
+					TraceID:      vptr("projects/jectpro/traces/fgh"),
+					SpanID:       vptr("foobar"),
+					TraceSampled: vptr(true),
+				},
+			},
+			{
+				"forced sampling without trace",
+				slogdriver.Config{
+					ProjectID: "jectpro",
+				},
+				slogdriver.ForceSample(context.Background()),
+				TraceInfo{},
+			},
+			{
+				"full resource name",
+				slogdriver.Config{
+					ProjectID: "ignored",
+				},
+				slogdriver.Trace{
+					ID: "projects/other/traces/fgh",
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/other/traces/fgh"),
+					TraceSampled: vptr(false),
+				},
+			},
+			{
+				"full resource name without project ID",
+				slogdriver.Config{},
+				slogdriver.Trace{
+					ID: "projects/other/traces/ghi",
+				}.Context(context.Background()),
+				TraceInfo{
+					TraceID:      vptr("projects/other/traces/ghi"),
+					TraceSampled: vptr(false),
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+
+				ctx := tt.ctx
+				var capture slogtest.Capture[TraceInfo]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.InfoContext(ctx, "trace")
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("trace without project ID", func(t *testing.T) {
+		type Entry struct {
+			TraceID      *string `json:"logging.googleapis.com/trace"`
+			SpanID       *string `json:"logging.googleapis.com/spanId"`
+			TraceSampled *bool   `json:"logging.googleapis.com/trace_sampled"`
+		}
+
+		tests := []struct {
+			name            string
+			config          slogdriver.Config
+			trace           slogdriver.Trace
+			expectedTraceID *string
+			expectedSpanID  *string
+			expectedErrors  []error
+		}{
+			{
+				"bare trace ID",
+				slogdriver.Config{},
+				slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true},
+				nil,
+				nil,
+				[]error{slogdriver.ErrMissingProjectID},
+			},
+			{
+				"span emitted",
+				slogdriver.Config{EmitSpanWithoutTrace: true},
+				slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true},
+				nil,
+				vptr("def"),
+				[]error{slogdriver.ErrMissingProjectID},
+			},
+			{
+				"full resource name",
+				slogdriver.Config{},
+				slogdriver.Trace{ID: "projects/jectpro/traces/abc", SpanID: "def"},
+				vptr("projects/jectpro/traces/abc"),
+				vptr("def"),
+				nil,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := tt.trace.Context(context.Background())
+				var capture slogtest.Capture[Entry]
+				var errs []error
+				tt.config.OnError = func(err error) {
+					errs = append(errs, err)
+				}
+				logger := slog.New(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.InfoContext(ctx, "first")
+				logger.With(slog.String("foo", "bar")).InfoContext(ctx, "second")
+				entries := capture.Entries()
+
+				require.Equal(t, tt.expectedErrors, errs)
+				require.Equal(t, false, strings.Contains(string(capture.Raw()), "projects//"))
+				for _, received := range entries {
+					require.Equal(t, tt.expectedTraceID == nil, received.TraceID == nil)
+					if tt.expectedTraceID != nil {
+						require.Equal(t, *tt.expectedTraceID, *received.TraceID)
+					}
+					require.Equal(t, tt.expectedSpanID == nil, received.SpanID == nil)
+					if tt.expectedSpanID != nil {
+						require.Equal(t, *tt.expectedSpanID, *received.SpanID)
+					}
+					require.Equal(t, tt.expectedTraceID == nil, received.TraceSampled == nil)
+				}
+			})
+		}
+	})
+
+	t.Run("trace attr", func(t *testing.T) {
+		type Entry struct {
+			TraceID *string        `json:"logging.googleapis.com/trace"`
+			SpanID  *string        `json:"logging.googleapis.com/spanId"`
+			Group   map[string]any `json:"Group"`
+			Trace   any            `json:"trace"`
+		}
+
+		tests := []struct {
+			name            string
+			ctx             context.Context
+			with            []slog.Attr
+			attrs           []slog.Attr
+			expectedTraceID *string
+			expectedSpanID  *string
+		}{
+			{
+				"none",
+				context.Background(),
+				nil,
+				nil,
+				nil,
+				nil,
+			},
+			{
+				"WithAttrs",
+				context.Background(),
+				[]slog.Attr{slogdriver.TraceAttr(slogdriver.Trace{ID: "abc", SpanID: "def"})},
+				nil,
+				vptr("projects/jectpro/traces/abc"),
+				vptr("def"),
+			},
+			{
+				"record",
+				context.Background(),
+				nil,
+				[]slog.Attr{slogdriver.TraceAttr(slogdriver.Trace{ID: "abc", SpanID: "def"})},
+				vptr("projects/jectpro/traces/abc"),
+				vptr("def"),
+			},
+			{
+				"in group",
+				context.Background(),
+				[]slog.Attr{slog.Group("Group", slogdriver.TraceAttr(slogdriver.Trace{ID: "abc"}))},
+				nil,
+				vptr("projects/jectpro/traces/abc"),
+				nil,
+			},
+			{
+				"overrides context",
+				slogdriver.Trace{ID: "ctx", SpanID: "ctx"}.Context(context.Background()),
+				[]slog.Attr{slogdriver.TraceAttr(slogdriver.Trace{ID: "abc"})},
+				nil,
+				vptr("projects/jectpro/traces/abc"),
+				nil,
+			},
+			{
+				"record overrides WithAttrs",
+				context.Background(),
+				[]slog.Attr{slogdriver.TraceAttr(slogdriver.Trace{ID: "abc"})},
+				[]slog.Attr{slogdriver.TraceAttr(slogdriver.Trace{ID: "bcd", SpanID: "def"})},
+				vptr("projects/jectpro/traces/bcd"),
+				vptr("def"),
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					ProjectID: "jectpro",
+				}))
+				logger = slog.New(logger.Handler().WithAttrs(tt.with))
+
+				logger.LogAttrs(tt.ctx, slog.LevelInfo, "trace", tt.attrs...)
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedTraceID == nil, received.TraceID == nil)
+				if tt.expectedTraceID != nil {
+					require.Equal(t, *tt.expectedTraceID, *received.TraceID)
+				}
+				require.Equal(t, tt.expectedSpanID == nil, received.SpanID == nil)
+				if tt.expectedSpanID != nil {
+					require.Equal(t, *tt.expectedSpanID, *received.SpanID)
+				}
+				require.Equal(t, true, received.Group == nil)
+				require.Equal(t, true, received.Trace == nil)
+			})
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		type Entry struct {
+			Labels map[string]string `json:"logging.googleapis.com/labels"`
+		}
+
+		tests := []struct {
+			name     string
+			ctx      context.Context
+			expected map[string]string
+		}{
+			{
+				"no labels",
+				context.Background(),
+				nil,
+			},
+			{
+				"empty labels",
+				slogdriver.AddLabels(
+					context.Background(),
+				),
+				nil,
+			},
+			{
+				"some labels",
+				slogdriver.AddLabels(
+					context.Background(),
+					slogdriver.NewLabel("foo", "bar"),
+					slogdriver.NewLabel("voo", "doo"),
+				),
+				map[string]string{
+					"foo": "bar",
+					"voo": "doo",
+				},
+			},
+			{
+				"extended labels",
+				slogdriver.AddLabels(
+					slogdriver.AddLabels(
+						context.Background(),
+						slogdriver.NewLabel("first", "1"),
+						slogdriver.NewLabel("second", "2"),
+					),
+					slogdriver.NewLabel("second", "changed"),
+					slogdriver.NewLabel("third", "3"),
+				),
+				map[string]string{
+					"first":  "1",
+					"second": "changed",
+					"third":  "3",
+				},
+			},
+			{
+				"reset labels",
+				slogdriver.ResetLabels(
+					slogdriver.AddLabels(
+						context.Background(),
+						slogdriver.NewLabel("first", "1"),
+					),
+				),
+				nil,
+			},
+			{
+				"labels after reset",
+				slogdriver.AddLabels(
+					slogdriver.ResetLabels(
+						slogdriver.AddLabels(
+							context.Background(),
+							slogdriver.NewLabel("first", "1"),
+						),
+					),
+					slogdriver.NewLabel("second", "2"),
+				),
+				map[string]string{
+					"second": "2",
+				},
+			},
+			{
+				"local labels",
+				slogdriver.AddLocalLabels(
+					slogdriver.AddLabels(
+						context.Background(),
+						slogdriver.NewLabel("first", "1"),
+					),
+					slogdriver.NewLabel("local", "2"),
+				),
+				map[string]string{
+					"first": "1",
+					"local": "2",
+				},
+			},
+			{
+				"labels after local labels",
+				slogdriver.AddLabels(
+					slogdriver.AddLocalLabels(
+						slogdriver.AddLabels(
+							context.Background(),
+							slogdriver.NewLabel("first", "1"),
+						),
+						slogdriver.NewLabel("local", "2"),
+					),
+					slogdriver.NewLabel("third", "3"),
+				),
+				map[string]string{
+					"first": "1",
+					"third": "3",
+				},
+			},
+			{
+				"local labels after local labels",
+				slogdriver.AddLocalLabels(
+					slogdriver.AddLocalLabels(
+						context.Background(),
+						slogdriver.NewLabel("local", "1"),
+					),
+					slogdriver.NewLabel("other", "2"),
+				),
+				map[string]string{
+					"other": "2",
+				},
+			},
+			{
+				"local labels in derived context",
+				func() context.Context {
+					ctx, cancel := context.WithCancel(slogdriver.AddLocalLabels(
+						context.Background(),
+						slogdriver.NewLabel("local", "1"),
+					))
+					cancel()
+					return ctx
+				}(),
+				map[string]string{
+					"local": "1",
+				},
+			},
+			{
+				"labels from map",
+				slogdriver.AddLabelsMap(
+					slogdriver.AddLabels(
+						context.Background(),
+						slogdriver.NewLabel("first", "1"),
+						slogdriver.NewLabel("second", "2"),
+					),
+					map[string]string{
+						"second": "changed",
+						"third":  "3",
+					},
+				),
+				map[string]string{
+					"first":  "1",
+					"second": "changed",
+					"third":  "3",
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+
+				ctx := tt.ctx
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+
+				logger.InfoContext(ctx, "labels")
+				entries := capture.Entries()
+				received := entries[0].Labels
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("resource", func(t *testing.T) {
+		type Entry struct {
+			Resource *slogdriver.MonitoredResource `json:"resource"`
+		}
+
+		configResource := slogdriver.MonitoredResource{
+			Type:   "global",
+			Labels: map[string]string{"project_id": "jectpro"},
+		}
+		topicResource := slogdriver.MonitoredResource{
+			Type:   "pubsub_topic",
+			Labels: map[string]string{"topic_id": "events"},
+		}
+
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			ctx      context.Context
+			expected *slogdriver.MonitoredResource
+		}{
+			{
+				"no resource",
+				slogdriver.Config{},
+				context.Background(),
+				nil,
+			},
+			{
+				"config resource",
+				slogdriver.Config{Resource: configResource},
+				context.Background(),
+				&configResource,
+			},
+			{
+				"context resource",
+				slogdriver.Config{Resource: configResource},
+				slogdriver.WithResource(context.Background(), topicResource),
+				&topicResource,
+			},
+			{
+				"context resource without config resource",
+				slogdriver.Config{},
+				slogdriver.WithResource(context.Background(), topicResource),
+				&topicResource,
+			},
+			{
+				"empty context resource",
+				slogdriver.Config{Resource: configResource},
+				slogdriver.WithResource(context.Background(), slogdriver.MonitoredResource{}),
+				&configResource,
+			},
+			{
+				"resource without labels",
+				slogdriver.Config{Resource: slogdriver.MonitoredResource{Type: "global"}},
+				context.Background(),
+				&slogdriver.MonitoredResource{Type: "global"},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.InfoContext(tt.ctx, "resource")
+				entries := capture.Entries()
+				received := entries[0].Resource
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("label style", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			style    slogdriver.LabelStyle
+			expected map[string]any
+		}{
+			{
+				"nested",
+				slogdriver.LabelStyleNested,
+				map[string]any{
+					"logging.googleapis.com/labels": map[string]any{
+						"foo": "bar",
+						"voo": "doo",
+					},
+				},
+			},
+			{
+				"prefixed",
+				slogdriver.LabelStylePrefixed,
+				map[string]any{
+					"labels.foo": "bar",
+					"labels.voo": "doo",
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := slogdriver.AddLabels(context.Background(), slogdriver.NewLabel("foo", "bar"))
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+					LabelStyle: tt.style,
+				}))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "labels", slog.Any("label", slogdriver.NewLabel("voo", "doo")))
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				for key, expected := range tt.expected {
+					require.Equal(t, expected, received[key], key)
+				}
+				require.Equal(t, tt.style == slogdriver.LabelStyleNested, hasKey(received, slogdriver.FieldLabels))
+				require.Equal(t, tt.style == slogdriver.LabelStylePrefixed, hasKey(received, "labels.foo"))
+			})
+		}
+	})
+
+	t.Run("label attrs", func(t *testing.T) {
+		type Entry struct {
+			Labels  map[string]string `json:"logging.googleapis.com/labels"`
+			Payload map[string]any    `json:"Group"`
+		}
+
+		tests := []struct {
+			name     string
+			with     []slog.Attr
+			attrs    []slog.Attr
+			expected map[string]string
+		}{
+			{
+				"single label",
+				nil,
+				[]slog.Attr{slog.Any("Label", slogdriver.NewLabel("foo", "bar"))},
+				map[string]string{
+					"context": "1",
+					"foo":     "bar",
+				},
+			},
+			{
+				"multiple labels",
+				[]slog.Attr{slog.Any("Label", slogdriver.NewLabel("prepared", "1"))},
+				[]slog.Attr{
+					slog.Any("Label", slogdriver.NewLabel("foo", "bar")),
+					slog.String("Other", "value"),
+					slog.Any("Label", slogdriver.NewLabel("context", "changed")),
+				},
+				map[string]string{
+					"context":  "changed",
+					"prepared": "1",
+					"foo":      "bar",
+				},
+			},
+			{
+				"nested labels",
+				[]slog.Attr{slog.Group("", slog.Any("Label", slogdriver.NewLabel("prepared", "1")))},
+				[]slog.Attr{slog.Group("Inner", slog.Any("Label", slogdriver.NewLabel("foo", "bar")))},
+				map[string]string{
+					"context":  "1",
+					"prepared": "1",
+					"foo":      "bar",
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := slogdriver.AddLabels(context.Background(), slogdriver.NewLabel("context", "1"))
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+				logger = slog.New(logger.Handler().WithAttrs(tt.with).WithGroup("Group"))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "labels", tt.attrs...)
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received.Labels)
+				require.Equal(t, false, hasKey(received.Payload, "Label"))
+				require.Equal(t, false, hasKey(received.Payload, "Inner"))
+			})
+		}
+	})
+
+	t.Run("labels in groups", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			config slogdriver.Config
+		}{
+			{"default", slogdriver.Config{}},
+			{"flattened", slogdriver.Config{FlattenGroups: true}},
+			{"nested payload", slogdriver.Config{NestPayload: true}},
+			{"dedup", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := slogdriver.AddLabels(context.Background(), slogdriver.NewLabel("context", "1"))
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				logger = logger.WithGroup("Outer").With(slog.Any("Label", slogdriver.NewLabel("prepared", "2")))
+				logger = logger.WithGroup("Inner")
+				var expected any = map[string]any{
+					"context":  "1",
+					"prepared": "2",
+					"record":   "3",
+				}
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "labels",
+					slog.Any("Label", slogdriver.NewLabel("record", "3")),
+					slog.String("Other", "value"),
+				)
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, expected, received["logging.googleapis.com/labels"])
+				raw := string(capture.Raw())
+				require.Equal(t, 1, strings.Count(raw, "labels\":"), raw)
+				require.Equal(t, false, strings.Contains(raw, `"Label"`))
+			})
+		}
+	})
+
+	t.Run("labels from valuers", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			config slogdriver.Config
+		}{
+			{"default", slogdriver.Config{}},
+			{"flattened", slogdriver.Config{FlattenGroups: true}},
+			{"dedup", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				logger = logger.With(slog.Any("Tenant", ValuerFunc(func() slog.Value {
+					return slog.AnyValue(slogdriver.NewLabel("tenant", "acme"))
+				})))
+				var expected any = map[string]any{
+					"tenant": "acme",
+					"user":   "someone",
+					"role":   "admin",
+				}
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "labels",
+					slog.Any("User", ValuerFunc(func() slog.Value {
+						return slog.GroupValue(
+							slog.Any("Name", slogdriver.NewLabel("user", "someone")),
+							slog.Any("Role", slogdriver.NewLabel("role", "admin")),
+						)
+					})),
+					slog.String("Other", "value"),
+				)
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, expected, received["logging.googleapis.com/labels"])
+				require.Equal(t, false, hasKey(received, "User"))
+				require.Equal(t, false, hasKey(received, "Tenant"))
+				require.Equal[any](t, "value", received["Other"])
+			})
+		}
+	})
+
+	t.Run("LogValue calls", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			config slogdriver.Config
+		}{
+			{"default", slogdriver.Config{}},
+			{"sorted labels", slogdriver.Config{SortLabels: true}},
+			{"escalate on error", slogdriver.Config{EscalateOnError: true}},
+			{"stack traces", slogdriver.Config{ReportErrors: true, ReportStackTraces: true}},
+			{"allowed keys", slogdriver.Config{AllowedKeys: []string{"User", "Group", "Nested"}}},
+			{"dedup", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}},
+			{"max attrs", slogdriver.Config{MaxAttrs: 1}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				calls := 0
+				user := ValuerFunc(func() slog.Value {
+					calls++
+					return slog.GroupValue(
+						slog.Any("Name", slogdriver.NewLabel("user", "someone")),
+						slog.String("Role", "admin"),
+					)
+				})
+				nested := ValuerFunc(func() slog.Value {
+					calls++
+					return slog.AnyValue(slogdriver.Trace{ID: "abc"})
+				})
+
+				logger.LogAttrs(ctx, slog.LevelError, "calls",
+					slog.Any("User", user),
+					slog.Group("Group", slog.Any("Nested", nested)),
+				)
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, 1, len(capture.Entries()))
+				require.Equal(t, 2, calls)
+			})
+		}
+	})
+
+	t.Run("LogValue calls of WithAttrs", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			config slogdriver.Config
+		}{
+			{"default", slogdriver.Config{}},
+			{"omitted", slogdriver.Config{AllowedKeys: []string{"Other"}}},
+			{"dedup", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}},
+			{"sort keys", slogdriver.Config{SortKeys: true}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				calls := 0
+				user := ValuerFunc(func() slog.Value {
+					calls++
+					return slog.GroupValue(
+						slog.Any("Name", slogdriver.NewLabel("user", "someone")),
+						slog.Any("Trace", slogdriver.Trace{ID: "abc"}),
+						slog.String("Role", "admin"),
+					)
+				})
+
+				logger = logger.With(slog.Any("User", user))
+				logger.InfoContext(ctx, "first")
+				logger.InfoContext(ctx, "second")
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, 2, len(capture.Entries()))
+				require.Equal(t, 1, calls)
+				for _, entry := range capture.Entries() {
+					require.Equal[any](t, map[string]any{"user": "someone"}, entry[slogdriver.FieldLabels])
+				}
+			})
+		}
+	})
+
+	t.Run("sorted labels", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			expected string
+		}{
+			{
+				"nested",
+				slogdriver.Config{SortLabels: true},
+				`"logging.googleapis.com/labels":{"a":"1","b":"changed","c":"3","d":"4"}`,
+			},
+			{
+				"prefixed",
+				slogdriver.Config{SortLabels: true, LabelStyle: slogdriver.LabelStylePrefixed},
+				`"labels.a":"1","labels.b":"changed","labels.c":"3","labels.d":"4"`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := slogdriver.AddLabels(context.Background(),
+					slogdriver.NewLabel("d", "4"),
+					slogdriver.NewLabel("b", "2"),
+				)
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+				logger = logger.With(slog.Any("Label", slogdriver.NewLabel("c", "3")))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "labels",
+					slog.Any("Label", slogdriver.NewLabel("b", "changed")),
+					slog.Any("Label", slogdriver.NewLabel("a", "1")),
+				)
+				raw := string(capture.Raw())
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, true, strings.Contains(raw, tt.expected), raw)
+			})
+		}
+	})
+
+	t.Run("redact", func(t *testing.T) {
+		redacted := slog.StringValue("[REDACTED]")
+		tests := []struct {
+			name     string
+			redact   func(groups []string, key string, v slog.Value) (slog.Value, bool)
+			expected map[string]any
+		}{
+			{
+				"key suffix",
+				func(groups []string, key string, v slog.Value) (slog.Value, bool) {
+					return redacted, strings.HasSuffix(key, "_token")
+				},
+				map[string]any{
+					"api_token": "[REDACTED]",
+					"Group": map[string]any{
+						"user":         "someone@example.com",
+						"access_token": "[REDACTED]",
+						"Nested": map[string]any{
+							"refresh_token": "[REDACTED]",
+							"count":         float64(1),
+						},
+					},
+				},
+			},
+			{
+				"value",
+				func(groups []string, key string, v slog.Value) (slog.Value, bool) {
+					return redacted, v.Kind() == slog.KindString && strings.Contains(v.String(), "@")
+				},
+				map[string]any{
+					"api_token": "abc",
+					"Group": map[string]any{
+						"user":         "[REDACTED]",
+						"access_token": "def",
+						"Nested": map[string]any{
+							"refresh_token": "ghi",
+							"count":         float64(1),
+						},
+					},
+				},
+			},
+			{
+				"groups",
+				func(groups []string, key string, v slog.Value) (slog.Value, bool) {
+					return redacted, slices.Equal(groups, []string{"Group", "Nested"})
+				},
+				map[string]any{
+					"api_token": "abc",
+					"Group": map[string]any{
+						"user":         "someone@example.com",
+						"access_token": "def",
+						"Nested": map[string]any{
+							"refresh_token": "[REDACTED]",
+							"count":         "[REDACTED]",
+						},
+					},
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[map[string]any]
+				var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+					Redact: tt.redact,
+				})
+				h = h.WithAttrs([]slog.Attr{slog.String("api_token", "abc")})
+				h = h.WithGroup("Group")
+				h = h.WithAttrs([]slog.Attr{slog.String("user", "someone@example.com")})
+				logger, errs := slogtest.NewWithErrorHandler(h)
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "redact",
+					slog.String("access_token", "def"),
+					slog.Group("Nested",
+						slog.String("refresh_token", "ghi"),
+						slog.Int("count", 1),
+					),
+				)
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				for key, expected := range tt.expected {
+					require.Equal(t, expected, received[key], key)
+				}
+			})
+		}
+	})
+
+	t.Run("dedup attrs", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			mode     slogdriver.DedupMode
+			expected string
+		}{
+			{
+				"none",
+				slogdriver.DedupNone,
+				`{"a":"prepared","a":"added","g":{"b":"prepared","b":"added","n":{"c":1,"c":2}}}`,
+			},
+			{
+				"last wins",
+				slogdriver.DedupLastWins,
+				`{"a":"added","g":{"b":"added","n":{"c":2}}}`,
+			},
+			{
+				"first wins",
+				slogdriver.DedupFirstWins,
+				`{"a":"prepared","g":{"b":"prepared","n":{"c":1}}}`,
+			},
+			{
+				"rename",
+				slogdriver.DedupRename,
+				`{"a":"prepared","a#2":"added","g":{"b":"prepared","b#2":"added","n":{"c":1,"c#2":2}}}`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				type Entry struct {
+					Payload json.RawMessage `json:"jsonPayload"`
+				}
+
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+					DedupAttrs:  tt.mode,
+					NestPayload: true,
+				})
+				h = h.WithAttrs([]slog.Attr{slog.String("a", "prepared")})
+				h = h.WithAttrs([]slog.Attr{slog.String("a", "added")})
+				h = h.WithGroup("g")
+				h = h.WithAttrs([]slog.Attr{slog.String("b", "prepared")})
+				logger, errs := slogtest.NewWithErrorHandler(h)
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "dedup",
+					slog.String("b", "added"),
+					slog.Group("n", slog.Int("c", 1), slog.Int("c", 2)),
+				)
+				entries := capture.Entries()
+				received := entries[0].Payload
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, string(received))
+			})
+		}
+	})
+
+	t.Run("allowed keys", func(t *testing.T) {
+		ctx := context.Background()
+		var capture slogtest.Capture[map[string]any]
+		var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+			AllowedKeys: []string{"allowed", "Group", "nested"},
+		})
+		h = h.WithAttrs([]slog.Attr{
+			slog.String("allowed", "prepared"),
+			slog.String("secret", "prepared"),
+		})
+		h = h.WithAttrs([]slog.Attr{
+			slog.String("secret", "only"),
+			slog.Group("", slog.String("secret", "inline")),
+		})
+		h = h.WithGroup("Group")
+		logger, errs := slogtest.NewWithErrorHandler(h)
+		expected := map[string]any{
+			"allowed": "prepared",
+			"Group": map[string]any{
+				"allowed": "added",
+				"nested": map[string]any{
+					"allowed": "nested",
+				},
+			},
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "allowed",
+			slog.String("allowed", "added"),
+			slog.String("secret", "added"),
+			slog.Group("nested",
+				slog.String("allowed", "nested"),
+				slog.String("secret", "nested"),
+			),
+			slog.Group("secret", slog.String("allowed", "in secret group")),
+		)
+		entries := capture.Entries()
+		received := entries[0]
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, "allowed", received["message"])
+		require.Equal(t, true, hasKey(received, "severity"))
+		require.Equal(t, true, hasKey(received, "timestamp"))
+		for key, expected := range expected {
+			require.Equal(t, expected, received[key], key)
+		}
+		require.Equal(t, false, hasKey(received, "secret"))
+	})
+
+	t.Run("logger name", func(t *testing.T) {
+		type Entry struct {
+			Logger *string `json:"logger"`
+		}
+
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			names    []string
+			expected *string
+		}{
+			{"no name", slogdriver.Config{}, nil, nil},
+			{"config name", slogdriver.Config{Name: "server"}, nil, vptr("server")},
+			{"named", slogdriver.Config{}, []string{"server"}, vptr("server")},
+			{"chained", slogdriver.Config{Name: "server"}, []string{"db", "pool"}, vptr("server.db.pool")},
+			{"empty named", slogdriver.Config{Name: "server"}, []string{""}, vptr("server")},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				h := slogdriver.NewHandler(&capture, tt.config)
+				for _, name := range tt.names {
+					h = h.Named(name)
+				}
+				logger, errs := slogtest.NewWithErrorHandler(h)
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "named")
+				entries := capture.Entries()
+				received := entries[0].Logger
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected == nil, received == nil)
+				if tt.expected != nil {
+					require.Equal(t, *tt.expected, *received)
+				}
+			})
+		}
+	})
+
+	t.Run("request ID", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			id       string
+			expected map[string]any
+		}{
+			{"absent", slogdriver.Config{}, "", map[string]any{}},
+			{"present", slogdriver.Config{}, "req-123", map[string]any{"request_id": "req-123"}},
+			{"custom key", slogdriver.Config{RequestIDKey: "requestId"}, "req-123", map[string]any{"requestId": "req-123"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.id != "" {
+					ctx = slogdriver.WithRequestID(ctx, tt.id)
+				}
+				var capture slogtest.Capture[map[string]any]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, slog.LevelInfo, "request")
+				entries := capture.Entries()
+				received := map[string]any{}
+				for _, key := range []string{"request_id", "requestId"} {
+					if v, ok := entries[0][key]; ok {
+						received[key] = v
+					}
+				}
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("error reporting", func(t *testing.T) {
+		type ServiceContext struct {
+			Service string `json:"service"`
+			Version string `json:"version"`
+		}
+
+		type Entry struct {
+			Type           *string         `json:"@type"`
+			ServiceContext *ServiceContext `json:"serviceContext"`
+		}
+
+		tests := []struct {
+			name     string
+			config   slogdriver.Config
+			level    slog.Level
+			expected Entry
+		}{
+			{
+				"disabled",
+				slogdriver.Config{
+					ServiceContext: slogdriver.ServiceContext{Service: "svc"},
+				},
+				slog.LevelError,
+				Entry{},
+			},
+			{
+				"below error",
+				slogdriver.Config{
+					ReportErrors:   true,
+					ServiceContext: slogdriver.ServiceContext{Service: "svc"},
+				},
+				slog.LevelWarn,
+				Entry{},
+			},
+			{
+				"no service",
+				slogdriver.Config{
+					ReportErrors: true,
+				},
+				slog.LevelError,
+				Entry{
+					Type: vptr("type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"),
+				},
+			},
+			{
+				"service context",
+				slogdriver.Config{
+					ReportErrors: true,
+					ServiceContext: slogdriver.ServiceContext{
+						Service: "svc",
+						Version: "1.2.3",
+					},
+				},
+				slog.LevelError,
+				Entry{
+					Type: vptr("type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"),
+					ServiceContext: &ServiceContext{
+						Service: "svc",
+						Version: "1.2.3",
+					},
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, tt.level, "error reporting")
+				entries := capture.Entries()
+				received := entries[0]
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("stack traces", func(t *testing.T) {
+		type Entry struct {
+			StackTrace *string `json:"stack_trace"`
+		}
+
+		var pcs [8]uintptr
+		n := runtime.Callers(1, pcs[:])
+		stackErr := &StackTraceError{Message: "failed", Frames: make([]StackFrame, n)}
+		for i, pc := range pcs[:n] {
+			stackErr.Frames[i] = StackFrame(pc)
+		}
+
+		tests := []struct {
+			name           string
+			config         slogdriver.Config
+			level          slog.Level
+			attrs          []slog.Attr
+			expectedPrefix *string
+		}{
+			{
+				"disabled",
+				slogdriver.Config{ReportErrors: true},
+				slog.LevelError,
+				[]slog.Attr{slog.Any("error", stackErr)},
+				nil,
+			},
+			{
+				"below error",
+				slogdriver.Config{ReportErrors: true, ReportStackTraces: true},
+				slog.LevelWarn,
+				[]slog.Attr{slog.Any("error", stackErr)},
+				nil,
+			},
+			{
+				"stack tracer",
+				slogdriver.Config{ReportErrors: true, ReportStackTraces: true},
+				slog.LevelError,
+				[]slog.Attr{slog.String("other", "value"), slog.Any("error", stackErr)},
+				vptr("failed\n\ngoroutine "),
+			},
+			{
+				"wrapped stack tracer",
+				slogdriver.Config{ReportErrors: true, ReportStackTraces: true},
+				slog.LevelError,
+				[]slog.Attr{slog.Any("error", fmt.Errorf("wrapped: %w", stackErr))},
+				vptr("wrapped: failed\n\ngoroutine "),
+			},
+			{
+				"plain error",
+				slogdriver.Config{ReportErrors: true, ReportStackTraces: true},
+				slog.LevelError,
+				[]slog.Attr{slog.Any("error", errors.New("plain"))},
+				vptr("plain\n\ngoroutine "),
+			},
+			{
+				"no error",
+				slogdriver.Config{ReportErrors: true, ReportStackTraces: true},
+				slog.LevelError,
+				nil,
+				vptr("stack traces\n\ngoroutine "),
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				var capture slogtest.Capture[Entry]
+				logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+				logger.LogAttrs(ctx, tt.level, "stack traces", tt.attrs...)
+				entries := capture.Entries()
+				received := entries[0].StackTrace
+				err := errs.Err()
+
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedPrefix == nil, received == nil)
+				if tt.expectedPrefix != nil {
+					require.Equal(t, true, strings.HasPrefix(*received, *tt.expectedPrefix), *received)
+					require.Equal(t, true, strings.Contains(*received, "slogdriver_test.TestHandler"), *received)
+					require.Equal(t, true, strings.Contains(*received, "handler_test.go:"), *received)
+				}
+			})
+		}
+
+		t.Run("frames", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				ReportErrors:      true,
+				ReportStackTraces: true,
+			}))
+
+			logger.LogAttrs(ctx, slog.LevelError, "stack traces", slog.Any("error", stackErr))
+			entries := capture.Entries()
+			received := *entries[0].StackTrace
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, false, strings.Contains(received, "slogdriver.(*Handler)"), received)
+		})
+	})
+
+	t.Run("insertId", func(t *testing.T) {
+		type Entry struct {
+			InsertID *string `json:"logging.googleapis.com/insertId"`
+			Worker   int
+		}
+
+		t.Run("disabled", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+
+			logger.LogAttrs(ctx, slog.LevelInfo, "insertId")
+			entries := capture.Entries()
+			received := entries[0].InsertID
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, nil, received)
+		})
+
+		t.Run("concurrent", func(t *testing.T) {
+			const (
+				workers = 8
+				logs    = 100
+			)
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				GenerateInsertID: true,
+			}))
+
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(worker int) {
+					defer wg.Done()
+					for j := 0; j < logs; j++ {
+						logger.LogAttrs(ctx, slog.LevelInfo, "insertId", slog.Int("Worker", worker))
+					}
+				}(i)
+			}
+			wg.Wait()
+			entries := capture.Entries()
+			lastByWorker := make(map[int]string)
+			ids := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				id := *entry.InsertID
+				require.Equal(t, true, lastByWorker[entry.Worker] < id, "insertIds must increase within a goroutine")
+				lastByWorker[entry.Worker] = id
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			ids = slices.Compact(ids)
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, workers*logs, len(ids))
+		})
+	})
+
 	t.Run("groups and attrs", func(t *testing.T) {
 		t.Run("nested", func(t *testing.T) {
 			type Nested2 struct {
@@ -300,31 +2655,484 @@ func TestHandler(t *testing.T) {
 			}
 
 			type Entry struct {
-				CustomPrepared1 int
-				Nested1         Nested1
+				CustomPrepared1 int
+				Nested1         Nested1
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{})
+			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared1", 1)})
+			h = h.WithGroup("Nested1")
+			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared2", 2)})
+			h = h.WithGroup("Nested2")
+			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared3", 3)})
+			logger, errs := slogtest.NewWithErrorHandler(h)
+			expected := Entry{
+				CustomPrepared1: 1,
+				Nested1: Nested1{
+					CustomPrepared2: 2,
+					Nested2: Nested2{
+						CustomPrepared3: 3,
+						CustomAdded:     4,
+					},
+				},
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Int64("CustomAdded", 4))
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("order", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				config   slogdriver.Config
+				attrs    []slog.Attr
+				expected string
+			}{
+				{
+					"record attrs",
+					slogdriver.Config{},
+					[]slog.Attr{slog.Int64("E", 5), slog.Int64("D", 4)},
+					`{"message":"order","severity":500,"C":3,"B":2,"G1":{"A":1,"Z":0,"G2":{"G3":{"Y":9},"E":5,"D":4}}}` + "\n",
+				},
+				{
+					"no record attrs",
+					slogdriver.Config{},
+					nil,
+					`{"message":"order","severity":500,"C":3,"B":2,"G1":{"A":1,"Z":0,"G2":{"G3":{"Y":9}}}}` + "\n",
+				},
+				{
+					"sorted",
+					slogdriver.Config{SortKeys: true},
+					[]slog.Attr{slog.Int64("E", 5), slog.Int64("D", 4)},
+					`{"message":"order","severity":500,"B":2,"C":3,"G1":{"A":1,"G2":{"D":4,"E":5,"G3":{"Y":9}},"Z":0}}` + "\n",
+				},
+				{
+					"sorted nested record group",
+					slogdriver.Config{SortKeys: true},
+					[]slog.Attr{slog.Group("F", slog.Int64("b", 2), slog.Int64("a", 1)), slog.Group("", slog.Int64("A", 0))},
+					`{"message":"order","severity":500,"B":2,"C":3,"G1":{"A":1,"G2":{"A":0,"F":{"a":1,"b":2},"G3":{"Y":9}},"Z":0}}` + "\n",
+				},
+				{
+					"sorted without dedup",
+					slogdriver.Config{SortKeys: true},
+					[]slog.Attr{slog.Int64("E", 5), slog.Int64("E", 6)},
+					`{"message":"order","severity":500,"B":2,"C":3,"G1":{"A":1,"G2":{"E":5,"E":6,"G3":{"Y":9}},"Z":0}}` + "\n",
+				},
+				{
+					"dedup",
+					slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins},
+					[]slog.Attr{slog.Int64("E", 5), slog.Int64("D", 4)},
+					`{"message":"order","severity":500,"C":3,"B":2,"G1":{"A":1,"Z":0,"G2":{"G3":{"Y":9},"E":5,"D":4}}}` + "\n",
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					var h slog.Handler = slogdriver.NewHandler(&capture, tt.config)
+					h = h.WithAttrs([]slog.Attr{slog.Int64("C", 3), slog.Int64("B", 2)})
+					h = h.WithGroup("G1")
+					h = h.WithAttrs([]slog.Attr{slog.Int64("A", 1)})
+					h = h.WithAttrs([]slog.Attr{slog.Int64("Z", 0)})
+					h = h.WithGroup("G2")
+					h = h.WithAttrs([]slog.Attr{slog.Group("G3", slog.Int64("Y", 9))})
+					r := slog.NewRecord(time.Time{}, slog.LevelError, "order", 0)
+					r.AddAttrs(tt.attrs...)
+
+					err := h.Handle(ctx, r)
+					received := string(capture.Raw())
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, received)
+				})
+			}
+		})
+
+		t.Run("flattened", func(t *testing.T) {
+			tests := []struct {
+				name      string
+				separator string
+				expected  map[string]any
+			}{
+				{
+					"default separator",
+					"",
+					map[string]any{
+						"CustomPrepared1":                         float64(1),
+						"Nested1.CustomPrepared2":                 float64(2),
+						"Nested1.Nested2.CustomPrepared3":         float64(3),
+						"Nested1.Nested2.CustomAdded":             float64(4),
+						"Nested1.Nested2.Nested3.CustomGroupAttr": "abc",
+					},
+				},
+				{
+					"custom separator",
+					"/",
+					map[string]any{
+						"CustomPrepared1":                         float64(1),
+						"Nested1/CustomPrepared2":                 float64(2),
+						"Nested1/Nested2/CustomPrepared3":         float64(3),
+						"Nested1/Nested2/CustomAdded":             float64(4),
+						"Nested1/Nested2/Nested3/CustomGroupAttr": "abc",
+					},
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+						FlattenGroups:  true,
+						GroupSeparator: tt.separator,
+					})
+					h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared1", 1)})
+					h = h.WithGroup("Nested1")
+					h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared2", 2)})
+					h = h.WithGroup("Nested2")
+					h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared3", 3)})
+					logger, errs := slogtest.NewWithErrorHandler(h)
+
+					logger.LogAttrs(ctx, slog.LevelError, "attrs",
+						slog.Int64("CustomAdded", 4),
+						slog.Group("Nested3", slog.String("CustomGroupAttr", "abc")),
+					)
+					entries := capture.Entries()
+					received := entries[0]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					for key, expected := range tt.expected {
+						require.Equal(t, expected, received[key], key)
+					}
+					require.Equal(t, false, hasKey(received, "Nested1"))
+				})
+			}
+		})
+
+		t.Run("numeric groups as arrays", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				config   slogdriver.Config
+				group    slog.Attr
+				expected any
+			}{
+				{
+					"sequential",
+					slogdriver.Config{NumericGroupsAsArrays: true},
+					slog.Group("Group", slog.String("0", "a"), slog.Int("1", 2), slog.Group("2", slog.Bool("Inner", true))),
+					[]any{"a", float64(2), map[string]any{"Inner": true}},
+				},
+				{
+					"nested",
+					slogdriver.Config{NumericGroupsAsArrays: true},
+					slog.Group("Group", slog.Group("0", slog.String("0", "a"), slog.String("1", "b"))),
+					[]any{[]any{"a", "b"}},
+				},
+				{
+					"not starting from zero",
+					slogdriver.Config{NumericGroupsAsArrays: true},
+					slog.Group("Group", slog.String("1", "a"), slog.String("2", "b")),
+					map[string]any{"1": "a", "2": "b"},
+				},
+				{
+					"non-sequential",
+					slogdriver.Config{NumericGroupsAsArrays: true},
+					slog.Group("Group", slog.String("0", "a"), slog.String("2", "b")),
+					map[string]any{"0": "a", "2": "b"},
+				},
+				{
+					"non-numeric",
+					slogdriver.Config{NumericGroupsAsArrays: true},
+					slog.Group("Group", slog.String("0", "a"), slog.String("b", "b")),
+					map[string]any{"0": "a", "b": "b"},
+				},
+				{
+					"disabled",
+					slogdriver.Config{},
+					slog.Group("Group", slog.String("0", "a"), slog.String("1", "b")),
+					map[string]any{"0": "a", "1": "b"},
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Group any
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "group", tt.group)
+					entries := capture.Entries()
+					received := entries[0].Group
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, received)
+				})
+			}
+		})
+
+		t.Run("key transform", func(t *testing.T) {
+			type Nested2 struct {
+				CustomPrepared3 int    `json:"custom_prepared3"`
+				CustomAdded     int    `json:"custom_added"`
+				CustomAny       string `json:"custom_any"`
+			}
+
+			type Nested1 struct {
+				CustomPrepared2 int     `json:"custom_prepared2"`
+				Nested2         Nested2 `json:"nested2"`
+			}
+
+			type Entry struct {
+				Message         string  `json:"message"`
+				CustomPrepared1 int     `json:"custom_prepared1"`
+				Nested1         Nested1 `json:"nested1"`
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{
+				KeyTransform: slogdriver.SnakeCase,
+			})
+			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared1", 1)})
+			h = h.WithGroup("Nested1")
+			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared2", 2)})
+			logger, errs := slogtest.NewWithErrorHandler(h)
+			expected := Entry{
+				Message:         "attrs",
+				CustomPrepared1: 1,
+				Nested1: Nested1{
+					CustomPrepared2: 2,
+					Nested2: Nested2{
+						CustomPrepared3: 3,
+						CustomAdded:     4,
+						CustomAny:       "any",
+					},
+				},
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Group("Nested2",
+				slog.Int64("CustomPrepared3", 3),
+				slog.Int64("CustomAdded", 4),
+				slog.Any("CustomAny", errors.New("any")),
+			))
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("nested payload", func(t *testing.T) {
+			type Group struct {
+				Val2 int
+			}
+
+			type Payload struct {
+				Prepared string
+				Val1     string
+				Group    Group
+			}
+
+			type Entry struct {
+				Message  string   `json:"message"`
+				Severity int      `json:"severity"`
+				Payload  *Payload `json:"jsonPayload"`
+				Prepared *string
+				Val1     *string
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			h := slogdriver.NewHandler(&capture, slogdriver.Config{
+				NestPayload: true,
+			}).WithAttrs([]slog.Attr{slog.String("Prepared", "prepared")})
+			logger, errs := slogtest.NewWithErrorHandler(h)
+			expected := Entry{
+				Message:  "attrs",
+				Severity: 500,
+				Payload: &Payload{
+					Prepared: "prepared",
+					Val1:     "abc",
+					Group:    Group{123},
+				},
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs",
+				slog.String("Val1", "abc"),
+				slog.Group("Group", slog.Int64("Val2", 123)),
+			)
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("payload key", func(t *testing.T) {
+			type Group struct {
+				Val2 int
+			}
+
+			type Payload struct {
+				Prepared string
+				Val1     string
+				Group    Group
+			}
+
+			type Entry struct {
+				Message  string   `json:"message"`
+				Severity int      `json:"severity"`
+				Data     *Payload `json:"data"`
+				Payload  *Payload `json:"jsonPayload"`
+				Val1     *string
+			}
+
+			configs := []struct {
+				name   string
+				config slogdriver.Config
+			}{
+				{"default", slogdriver.Config{PayloadKey: "data"}},
+				{"nested payload", slogdriver.Config{PayloadKey: "data", NestPayload: true}},
+				{"dedup", slogdriver.Config{PayloadKey: "data", DedupAttrs: slogdriver.DedupLastWins}},
+			}
+
+			for _, tt := range configs {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					h := slogdriver.NewHandler(&capture, tt.config).
+						WithAttrs([]slog.Attr{slog.String("Prepared", "prepared")})
+					logger, errs := slogtest.NewWithErrorHandler(h)
+					expected := Entry{
+						Message:  "attrs",
+						Severity: 500,
+						Data: &Payload{
+							Prepared: "prepared",
+							Val1:     "abc",
+							Group:    Group{123},
+						},
+					}
+
+					logger.LogAttrs(ctx, slog.LevelError, "attrs",
+						slog.String("Val1", "abc"),
+						slog.Group("Group", slog.Int64("Val2", 123)),
+					)
+					entries := capture.Entries()
+					received := entries[0]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, expected, received)
+				})
+			}
+		})
+
+		t.Run("nested payload without attrs", func(t *testing.T) {
+			type Entry struct {
+				Payload *struct{} `json:"jsonPayload"`
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				NestPayload: true,
+			}))
+			expected := Entry{}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs")
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("payload of consumed attrs", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				config slogdriver.Config
+				group  string
+				attrs  []slog.Attr
+			}{
+				{"labels", slogdriver.Config{}, "", []slog.Attr{slog.Any("label", slogdriver.NewLabel("foo", "bar"))}},
+				{"trace", slogdriver.Config{ProjectID: "jectpro"}, "", []slog.Attr{slog.Any("trace", slogdriver.Trace{ID: "abc"})}},
+				{"severity attr", slogdriver.Config{}, "", []slog.Attr{slog.String(slogdriver.SeverityAttrKey, "ALERT")}},
+				{"allowed keys", slogdriver.Config{AllowedKeys: []string{"allowed"}}, "", []slog.Attr{slog.String("other", "value")}},
+				{"labels in group", slogdriver.Config{}, "group", []slog.Attr{slog.Any("label", slogdriver.NewLabel("foo", "bar"))}},
+			}
+
+			for _, tt := range tests {
+				for _, path := range []struct {
+					name  string
+					dedup slogdriver.DedupMode
+				}{
+					{"pre-encoded", slogdriver.DedupNone},
+					{"deferred", slogdriver.DedupLastWins},
+				} {
+					t.Run(tt.name+" "+path.name, func(t *testing.T) {
+						ctx := context.Background()
+						var capture slogtest.Capture[map[string]any]
+						config := tt.config
+						config.PayloadKey = "data"
+						config.DedupAttrs = path.dedup
+						var h slog.Handler = slogdriver.NewHandler(&capture, config)
+						if tt.group != "" {
+							h = h.WithGroup(tt.group)
+						}
+						logger, errs := slogtest.NewWithErrorHandler(h)
+
+						logger.LogAttrs(ctx, slog.LevelInfo, "attrs", tt.attrs...)
+						entries := capture.Entries()
+						err := errs.Err()
+
+						require.NoError(t, err)
+						require.Equal(t, false, hasKey(entries[0], "data"))
+					})
+				}
+			}
+		})
+
+		t.Run("group", func(t *testing.T) {
+			type Group struct {
+				Val1 string
+				Val2 int
+			}
+
+			type Entry struct {
+				Group Group
 			}
 
 			ctx := context.Background()
 			var capture slogtest.Capture[Entry]
-			var h slog.Handler = slogdriver.NewHandler(&capture, slogdriver.Config{})
-			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared1", 1)})
-			h = h.WithGroup("Nested1")
-			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared2", 2)})
-			h = h.WithGroup("Nested2")
-			h = h.WithAttrs([]slog.Attr{slog.Int64("CustomPrepared3", 3)})
-			logger, errs := slogtest.NewWithErrorHandler(h)
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
 			expected := Entry{
-				CustomPrepared1: 1,
-				Nested1: Nested1{
-					CustomPrepared2: 2,
-					Nested2: Nested2{
-						CustomPrepared3: 3,
-						CustomAdded:     4,
-					},
+				Group: Group{
+					Val1: "abc",
+					Val2: 123,
 				},
 			}
 
-			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Int64("CustomAdded", 4))
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Group("Group",
+				slog.String("Val1", "abc"),
+				slog.Int64("Val2", 123),
+			))
 			entries := capture.Entries()
 			received := entries[0]
 			err := errs.Err()
@@ -333,7 +3141,7 @@ func TestHandler(t *testing.T) {
 			require.Equal(t, expected, received)
 		})
 
-		t.Run("group", func(t *testing.T) {
+		t.Run("attr slice", func(t *testing.T) {
 			type Group struct {
 				Val1 string
 				Val2 int
@@ -353,10 +3161,35 @@ func TestHandler(t *testing.T) {
 				},
 			}
 
-			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Group("Group",
-				slog.String("Val1", "abc"),
-				slog.Int64("Val2", 123),
-			))
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Attr{
+				Key: "Group",
+				Value: rawAnyValue([]slog.Attr{
+					slog.String("Val1", "abc"),
+					slog.Int64("Val2", 123),
+				}),
+			})
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("value", func(t *testing.T) {
+			type Entry struct {
+				IntVal int
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			expected := Entry{123}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Attr{
+				Key:   "IntVal",
+				Value: rawAnyValue(slog.Int64Value(123)),
+			})
 			entries := capture.Entries()
 			received := entries[0]
 			err := errs.Err()
@@ -506,24 +3339,608 @@ func TestHandler(t *testing.T) {
 			require.Equal(t, expected, received)
 		})
 
+		t.Run("numbers as strings", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				attr     slog.Attr
+				expected string
+			}{
+				{"int64", slog.Int64("Val", -123), "-123"},
+				{"int64 max", slog.Int64("Val", math.MaxInt64), "9223372036854775807"},
+				{"uint64", slog.Uint64("Val", 123), "123"},
+				{"uint64 max", slog.Uint64("Val", math.MaxUint64), "18446744073709551615"},
+				{"float64", slog.Float64("Val", 1.5), "1.5"},
+				{"float64 precision", slog.Float64("Val", math.Pi), "3.141592653589793"},
+				{"float64 large", slog.Float64("Val", 1e300), "1e+300"},
+				{"duration", slog.Duration("Val", 1500*time.Millisecond), "1500000000"},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Val  any
+						Bool any
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+						NumbersAsStrings: true,
+					}))
+					expected := Entry{tt.expected, true}
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs", tt.attr, slog.Bool("Bool", true))
+					entries := capture.Entries()
+					received := entries[0]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, expected, received)
+				})
+			}
+		})
+
+		t.Run("duration object", func(t *testing.T) {
+			type Entry struct {
+				DurationVal1 map[string]any
+				DurationVal2 map[string]any
+				DurationVal3 map[string]any
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				DurationFormat: slogdriver.DurationFormatObject,
+			}))
+			expected := Entry{
+				map[string]any{"nanos": float64(123456), "human": "123.456µs"},
+				map[string]any{"nanos": float64(-1500000000), "human": "-1.5s"},
+				map[string]any{"nanos": float64(0), "human": "0s"},
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs",
+				slog.Duration("DurationVal1", 123456),
+				slog.Duration("DurationVal2", -1500*time.Millisecond),
+				slog.Duration("DurationVal3", 0),
+			)
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
 		t.Run("time", func(t *testing.T) {
 			type Entry struct {
-				TimeVal1 string
-				TimeVal2 string
+				TimeVal1 string
+				TimeVal2 string
+			}
+
+			ctx := context.Background()
+			now := time.Now()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			expected := Entry{
+				"2023-06-15T19:24:13.123456789Z",
+				now.Round(0).Format(time.RFC3339Nano),
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs",
+				slog.Time("TimeVal1", time.Date(2023, 6, 15, 19, 24, 13, 123456789, time.FixedZone("gcp", 0))),
+				slog.Time("TimeVal2", now),
+			)
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("time format", func(t *testing.T) {
+			type Entry struct {
+				TimeVal string
+			}
+
+			tm := time.Date(2023, 6, 15, 21, 24, 13, 123456789, time.FixedZone("CEST", 2*60*60))
+			tests := []struct {
+				name     string
+				config   slogdriver.Config
+				value    time.Time
+				expected string
+			}{
+				{"default", slogdriver.Config{}, tm, "2023-06-15T21:24:13.123456789+02:00"},
+				{"UTC", slogdriver.Config{TimeAttrUTC: true}, tm, "2023-06-15T19:24:13.123456789Z"},
+				{
+					"milliseconds",
+					slogdriver.Config{TimeAttrFormat: "2006-01-02T15:04:05.000Z07:00"},
+					tm,
+					"2023-06-15T21:24:13.123+02:00",
+				},
+				{
+					"milliseconds UTC",
+					slogdriver.Config{TimeAttrFormat: "2006-01-02T15:04:05.000Z07:00", TimeAttrUTC: true},
+					tm,
+					"2023-06-15T19:24:13.123Z",
+				},
+				{"zero", slogdriver.Config{TimeAttrUTC: true}, time.Time{}, "0001-01-01T00:00:00Z"},
+				{
+					"far future",
+					slogdriver.Config{TimeAttrFormat: time.RFC3339, TimeAttrUTC: true},
+					time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+					"9999-12-31T23:59:59Z",
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+					logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Time("TimeVal", tt.value))
+					entries := capture.Entries()
+					received := entries[0].TimeVal
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, received)
+				})
+			}
+		})
+
+		t.Run("max attrs", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[map[string]any]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				MaxAttrs: 3,
+			}))
+			attrs := []slog.Attr{
+				slog.Int("a0", 0),
+				slog.Group("g1", slog.Int("a", 1), slog.Int("b", 2)),
+				slog.Int("a2", 2),
+				slog.Int("a3", 3),
+				slog.Int("a4", 4),
+			}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", attrs...)
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal[any](t, float64(2), received["attrs_truncated"])
+			require.Equal[any](t, float64(0), received["a0"])
+			require.Equal[any](t, map[string]any{"a": float64(1), "b": float64(2)}, received["g1"])
+			require.Equal[any](t, float64(2), received["a2"])
+			require.Equal(t, false, hasKey(received, "a3"))
+			require.Equal(t, false, hasKey(received, "a4"))
+		})
+
+		t.Run("max attrs not exceeded", func(t *testing.T) {
+			ctx := context.Background()
+			var capture slogtest.Capture[map[string]any]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				MaxAttrs: 2,
+			}))
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Int("a0", 0), slog.Int("a1", 1))
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, false, hasKey(received, "attrs_truncated"))
+			require.Equal[any](t, float64(1), received["a1"])
+		})
+
+		t.Run("max attrs marker", func(t *testing.T) {
+			tests := []struct {
+				name         string
+				config       slogdriver.Config
+				expectedKeys int
+			}{
+				{"default", slogdriver.Config{}, 3},
+				{"flattened", slogdriver.Config{FlattenGroups: true}, 4},
+				{"dedup", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}, 3},
+				{"sort keys", slogdriver.Config{SortKeys: true}, 3},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					config := tt.config
+					config.MaxAttrs = 2
+					config.PayloadKey = "data"
+					config.KeyTransform = strings.ToUpper
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, config))
+					logger = logger.With(slog.Int("w", 1)).WithGroup("g")
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs",
+						slog.Int("a0", 0),
+						slog.Int("a1", 1),
+						slog.Int("a2", 2),
+						slog.Int("a3", 3),
+					)
+					entries := capture.Entries()
+					received := entries[0]["data"].(map[string]any)
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal[any](t, float64(2), received["attrs_truncated"])
+					require.Equal[any](t, float64(1), received["W"])
+					require.Equal(t, tt.expectedKeys, len(received))
+					require.Equal(t, true, strings.HasSuffix(string(capture.Raw()), `,"attrs_truncated":2}}`+"\n"))
+				})
+			}
+		})
+
+		t.Run("LogValuer", func(t *testing.T) {
+			type CustomValuer struct {
+				Foo string `json:"foo"`
+				Bar string `json:"bar"`
+			}
+
+			type Entry struct {
+				CustomValuer CustomValuer
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			expected := Entry{CustomValuer{"abc", "def"}}
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("CustomValuer", ValuerFunc(func() slog.Value {
+				return slog.GroupValue(
+					slog.String("foo", "abc"),
+					slog.String("bar", "def"),
+				)
+			})))
+			received := capture.Entries()[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("maps", func(t *testing.T) {
+			tests := []struct {
+				name  string
+				value any
+			}{
+				{"string map", map[string]string{"b": "2", "a": "1", "c": "3"}},
+				{"empty string map", map[string]string{}},
+				{"nil string map", map[string]string(nil)},
+				{"nil any map", map[string]any(nil)},
+				{"any map", map[string]any{
+					"string": "abc",
+					"bool":   true,
+					"int":    -123,
+					"int64":  int64(456),
+					"float":  12.5,
+					"tiny":   1e-9,
+					"nil":    nil,
+					"slice":  []int{1, 2, 3},
+					"struct": struct{ Foo string }{"bar"},
+					"nested": map[string]any{
+						"z": "last",
+						"a": map[string]string{"y": "2", "x": "1"},
+					},
+				}},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Map json.RawMessage
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+					expected, err := json.Marshal(tt.value)
+					require.NoError(t, err)
+
+					logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("Map", tt.value))
+					entries := capture.Entries()
+					received := entries[0].Map
+					err = errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, string(expected), string(received))
+				})
+			}
+		})
+
+		t.Run("maps with string and number options", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				config   slogdriver.Config
+				value    any
+				expected string
+			}{
+				{
+					"numbers as strings",
+					slogdriver.Config{NumbersAsStrings: true},
+					map[string]any{"int": -123, "int64": int64(456), "float": 12.5, "nested": map[string]any{"int": 1}},
+					`{"float":"12.5","int":"-123","int64":"456","nested":{"int":"1"}}`,
+				},
+				{
+					"sanitize strings",
+					slogdriver.Config{SanitizeStrings: true},
+					map[string]any{"a\x00b": "c\x1bd", "nested": map[string]string{"e\x07": "f\x7f"}},
+					`{"ab":"cd","nested":{"e":"f"}}`,
+				},
+				{
+					"fix invalid utf8",
+					slogdriver.Config{FixInvalidUTF8: true},
+					map[string]string{"a\xffb": "c\xfed"},
+					"{\"a\uFFFDb\":\"c\uFFFDd\"}",
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Map json.RawMessage
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+					logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("Map", tt.value))
+					entries := capture.Entries()
+					received := entries[0].Map
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, string(received))
+				})
+			}
+		})
+
+		t.Run("nil", func(t *testing.T) {
+			type Struct struct {
+				Foo string
+			}
+
+			tests := []struct {
+				name   string
+				config slogdriver.Config
+				value  any
+			}{
+				{"untyped", slogdriver.Config{}, nil},
+				{"struct pointer", slogdriver.Config{}, (*Struct)(nil)},
+				{"map", slogdriver.Config{}, map[string]int(nil)},
+				{"string map", slogdriver.Config{}, map[string]string(nil)},
+				{"slice", slogdriver.Config{}, []string(nil)},
+				{"any slice", slogdriver.Config{}, []any(nil)},
+				{"error pointer", slogdriver.Config{}, (*PointerError)(nil)},
+				{"error pointer with type", slogdriver.Config{ErrorIncludeType: true}, (*PointerError)(nil)},
+				{"struct tag", slogdriver.Config{StructTag: "log"}, (*Struct)(nil)},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]json.RawMessage]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs", slog.Any("Nil", tt.value))
+					entries := capture.Entries()
+					received := entries[0]["Nil"]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, "null", string(received))
+				})
+			}
+		})
+
+		t.Run("error", func(t *testing.T) {
+			type Entry struct {
+				ErrorVal string
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			expected := Entry{"unknown error"}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("ErrorVal", errors.New("unknown error")))
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("list", func(t *testing.T) {
+			type Entry struct {
+				List json.RawMessage
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			expected := `[` +
+				`"unknown error",` +
+				`1,` +
+				`"x",` +
+				`null,` +
+				`{"message":"foo"},` +
+				`{"Val":"abc"},` +
+				`{"a":1},` +
+				`["nested error"]` +
+				`]`
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("List", []any{
+				errors.New("unknown error"),
+				1,
+				"x",
+				nil,
+				JSONError{"foo"},
+				struct{ Val string }{"abc"},
+				[]slog.Attr{slog.Int("a", 1)},
+				[]any{errors.New("nested error")},
+			}))
+			entries := capture.Entries()
+			received := entries[0].List
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, expected, string(received))
+		})
+
+		t.Run("struct tag", func(t *testing.T) {
+			type Inner struct {
+				Value string `json:"value" log:"inner_value"`
+			}
+			type Embedded struct {
+				Flattened bool `json:"flattened" log:"is_flattened"`
+			}
+			type Config struct {
+				Embedded
+				Name     string            `json:"name" log:"config_name"`
+				Secret   string            `json:"secret" log:"-"`
+				Empty    string            `json:"empty,omitempty" log:"empty_value,omitempty"`
+				Untagged int               `json:"untagged"`
+				Inner    *Inner            `json:"inner" log:"inner"`
+				List     []Inner           `json:"list" log:"items"`
+				Map      map[string]Inner  `json:"map" log:"by_key"`
+				Time     time.Time         `json:"time" log:"at"`
+				Labels   map[string]string `json:"labels,omitempty" log:"labels,omitempty"`
+				hidden   string
+			}
+
+			value := Config{
+				Embedded: Embedded{true},
+				Name:     "abc",
+				Secret:   "hunter2",
+				Untagged: 1,
+				Inner:    &Inner{"def"},
+				List:     []Inner{{"ghi"}},
+				Map:      map[string]Inner{"b": {"jkl"}, "a": {"mno"}},
+				Time:     time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+				hidden:   "hidden",
+			}
+
+			tests := []struct {
+				name      string
+				structTag string
+				expected  string
+			}{
+				{
+					"default",
+					"",
+					`{"flattened":true,"name":"abc","secret":"hunter2","untagged":1,` +
+						`"inner":{"value":"def"},"list":[{"value":"ghi"}],` +
+						`"map":{"a":{"value":"mno"},"b":{"value":"jkl"}},"time":"2023-01-02T03:04:05Z"}`,
+				},
+				{
+					"custom",
+					"log",
+					`{"is_flattened":true,"config_name":"abc","Untagged":1,` +
+						`"inner":{"inner_value":"def"},"items":[{"inner_value":"ghi"}],` +
+						`"by_key":{"a":{"inner_value":"mno"},"b":{"inner_value":"jkl"}},"at":"2023-01-02T03:04:05Z"}`,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Config json.RawMessage
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+						StructTag: tt.structTag,
+					}))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs", slog.Any("Config", value))
+					entries := capture.Entries()
+					received := entries[0].Config
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, string(received))
+				})
+			}
+		})
+
+		t.Run("struct tag cycle", func(t *testing.T) {
+			type Node struct {
+				Name string         `log:"name"`
+				Next *Node          `log:"next,omitempty"`
+				Map  map[string]any `log:"map,omitempty"`
+			}
+			cyclic := &Node{Name: "a"}
+			cyclic.Next = cyclic
+			cyclicMap := &Node{Name: "a", Map: map[string]any{}}
+			cyclicMap.Map["self"] = cyclicMap.Map
+			shared := &Node{Name: "shared"}
+
+			tests := []struct {
+				name     string
+				value    any
+				expected string
+				err      bool
+			}{
+				{"pointer", cyclic, `{"name":"a","next":null}`, true},
+				{"map", cyclicMap, `{"name":"a","map":{"self":null}}`, true},
+				{"shared", []*Node{shared, shared}, `[{"name":"shared"},{"name":"shared"}]`, false},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					type Entry struct {
+						Value json.RawMessage
+					}
+
+					ctx := context.Background()
+					var capture slogtest.Capture[Entry]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+						StructTag: "log",
+					}))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs", slog.Any("Value", tt.value))
+					entries := capture.Entries()
+					received := entries[0].Value
+					err := errs.Err()
+
+					require.Equal(t, tt.err, err != nil)
+					require.Equal(t, tt.expected, string(received))
+				})
+			}
+		})
+
+		t.Run("error with type", func(t *testing.T) {
+			type ErrorVal struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			}
+			type Entry struct {
+				ErrorVal     ErrorVal
+				JSONErrorVal JSONError
 			}
 
 			ctx := context.Background()
-			now := time.Now()
 			var capture slogtest.Capture[Entry]
-			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				ErrorIncludeType: true,
+			}))
 			expected := Entry{
-				"2023-06-15T19:24:13.123456789Z",
-				now.Round(0).Format(time.RFC3339Nano),
+				ErrorVal{"wrapped: unknown error", "*fmt.wrapError"},
+				JSONError{"foo"},
 			}
 
 			logger.LogAttrs(ctx, slog.LevelError, "attrs",
-				slog.Time("TimeVal1", time.Date(2023, 6, 15, 19, 24, 13, 123456789, time.FixedZone("gcp", 0))),
-				slog.Time("TimeVal2", now),
+				slog.Any("ErrorVal", fmt.Errorf("wrapped: %w", errors.New("unknown error"))),
+				slog.Any("JSONErrorVal", JSONError{"foo"}),
 			)
 			entries := capture.Entries()
 			received := entries[0]
@@ -533,50 +3950,178 @@ func TestHandler(t *testing.T) {
 			require.Equal(t, expected, received)
 		})
 
-		t.Run("LogValuer", func(t *testing.T) {
-			type CustomValuer struct {
-				Foo string `json:"foo"`
-				Bar string `json:"bar"`
-			}
-
+		t.Run("pointer receiver LogValuer", func(t *testing.T) {
 			type Entry struct {
-				CustomValuer CustomValuer
+				ByValue   string
+				ByPointer string
 			}
 
 			ctx := context.Background()
 			var capture slogtest.Capture[Entry]
 			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
-			expected := Entry{CustomValuer{"abc", "def"}}
-			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("CustomValuer", ValuerFunc(func() slog.Value {
-				return slog.GroupValue(
-					slog.String("foo", "abc"),
-					slog.String("bar", "def"),
-				)
-			})))
-			received := capture.Entries()[0]
+			expected := Entry{"resolved abc", "resolved def"}
+
+			logger.LogAttrs(ctx, slog.LevelInfo, "attrs",
+				slog.Any("ByValue", PointerValuer{"abc"}),
+				slog.Any("ByPointer", &PointerValuer{"def"}),
+			)
+			entries := capture.Entries()
+			received := entries[0]
 			err := errs.Err()
 
 			require.NoError(t, err)
 			require.Equal(t, expected, received)
 		})
 
-		t.Run("error", func(t *testing.T) {
-			type Entry struct {
-				ErrorVal string
-			}
-
+		t.Run("pointer receiver LogValuer returning its value", func(t *testing.T) {
 			ctx := context.Background()
-			var capture slogtest.Capture[Entry]
+			var capture slogtest.Capture[map[string]any]
 			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
-			expected := Entry{"unknown error"}
+			expected := map[string]any{"Value": "abc"}
 
-			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.Any("ErrorVal", errors.New("unknown error")))
+			logger.LogAttrs(ctx, slog.LevelInfo, "attrs",
+				slog.Any("ByValue", SelfValuer{"abc"}),
+				slog.Any("ByPointer", &SelfValuer{"abc"}),
+			)
 			entries := capture.Entries()
 			received := entries[0]
 			err := errs.Err()
 
 			require.NoError(t, err)
-			require.Equal(t, expected, received)
+			require.Equal[any](t, expected, received["ByValue"])
+			require.Equal[any](t, expected, received["ByPointer"])
+		})
+
+		t.Run("pointer receiver LogValuer returning a label", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				config slogdriver.Config
+			}{
+				{"pre-encoded", slogdriver.Config{}},
+				{"deferred", slogdriver.Config{DedupAttrs: slogdriver.DedupLastWins}},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+					expected := map[string]any{"tenant": "abc", "user": "def"}
+
+					logger.With(slog.Any("Tenant", LabelValuer{"tenant", "abc"})).LogAttrs(ctx, slog.LevelInfo, "attrs",
+						slog.Any("User", LabelValuer{"user", "def"}),
+					)
+					entries := capture.Entries()
+					received := entries[0]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal[any](t, expected, received[slogdriver.FieldLabels])
+					require.Equal(t, false, hasKey(received, "Tenant"))
+					require.Equal(t, false, hasKey(received, "User"))
+				})
+			}
+		})
+
+		t.Run("disable LogValuer resolution", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				config   slogdriver.Config
+				expected map[string]any
+			}{
+				{
+					"resolved",
+					slogdriver.Config{},
+					map[string]any{
+						"ByValue":   "resolved abc",
+						"ByPointer": "resolved def",
+						"List":      []any{"resolved ghi"},
+					},
+				},
+				{
+					"raw",
+					slogdriver.Config{DisableLogValuerResolution: true},
+					map[string]any{
+						"ByValue":   map[string]any{"Value": "abc"},
+						"ByPointer": map[string]any{"Value": "def"},
+						"List":      []any{map[string]any{"Value": "ghi"}},
+					},
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, tt.config))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs",
+						slog.Any("ByValue", PointerValuer{"abc"}),
+						slog.Any("ByPointer", &PointerValuer{"def"}),
+						slog.Any("List", []any{&PointerValuer{"ghi"}}),
+					)
+					entries := capture.Entries()
+					received := entries[0]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					for key, expected := range tt.expected {
+						require.Equal(t, expected, received[key], key)
+					}
+				})
+			}
+		})
+
+		t.Run("typed payload", func(t *testing.T) {
+			type Payload struct {
+				Name  string `json:"name"`
+				Count int    `json:"count"`
+			}
+
+			tests := []struct {
+				name     string
+				value    any
+				expected any
+			}{
+				{
+					"object",
+					Payload{"foo", 3},
+					map[string]any{"@type": "type.googleapis.com/pkg.Payload", "name": "foo", "count": float64(3)},
+				},
+				{
+					"empty object",
+					struct{}{},
+					map[string]any{"@type": "type.googleapis.com/pkg.Payload"},
+				},
+				{
+					"non-object",
+					"<foo>",
+					map[string]any{"@type": "type.googleapis.com/pkg.Payload", "value": "<foo>"},
+				},
+				{
+					"number",
+					42,
+					map[string]any{"@type": "type.googleapis.com/pkg.Payload", "value": float64(42)},
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+					var capture slogtest.Capture[map[string]any]
+					logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+
+					logger.LogAttrs(ctx, slog.LevelInfo, "attrs",
+						slog.Any("Payload", slogdriver.TypedPayload("type.googleapis.com/pkg.Payload", tt.value)),
+					)
+					entries := capture.Entries()
+					received := entries[0]["Payload"]
+					err := errs.Err()
+
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, received)
+				})
+			}
 		})
 
 		t.Run("error with custom marshal", func(t *testing.T) {
@@ -625,13 +4170,13 @@ func TestHandler(t *testing.T) {
 		t.Run("error", func(t *testing.T) {
 			type Entry struct {
 				Correct  string
-				Erroring *struct{}
+				Erroring string
 			}
 
 			ctx := context.Background()
 			var capture slogtest.Capture[Entry]
 			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
-			expected := Entry{"correct", nil}
+			expected := Entry{"correct", "!ERROR:json: error calling MarshalJSON for type *slogdriver_test.ErroringMarshal: cannot be marshaled"}
 
 			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.String("Correct", "correct"), slog.Any("erroring", ErroringMarshal{}))
 			entries := capture.Entries()
@@ -645,15 +4190,52 @@ func TestHandler(t *testing.T) {
 		t.Run("WithAttrs error", func(t *testing.T) {
 			type Entry struct {
 				Correct  string
-				Erroring *struct{}
+				Erroring string
 			}
 
 			ctx := context.Background()
 			var capture slogtest.Capture[Entry]
 			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
-			expected := Entry{"correct", nil}
+			logger = logger.With(slog.Any("Erroring", ErroringMarshal{}), slog.String("Correct", "correct"))
+			expected := Entry{"correct", "!ERROR:json: error calling MarshalJSON for type *slogdriver_test.ErroringMarshal: cannot be marshaled"}
+
+			logger.LogAttrs(ctx, slog.LevelError, "attrs")
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.Error(t, err)
+			require.Equal(t, expected, received)
+		})
+
+		t.Run("nested error", func(t *testing.T) {
+			type Group struct {
+				Erroring string
+				Correct  string
+				Map      map[string]string
+			}
+			type Entry struct {
+				Group Group
+				After string
+			}
+
+			ctx := context.Background()
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{}))
+			placeholder := "!ERROR:json: error calling MarshalJSON for type *slogdriver_test.ErroringMarshal: cannot be marshaled"
+			expected := Entry{
+				Group{placeholder, "correct", map[string]string{"Erroring": placeholder}},
+				"after",
+			}
 
-			logger.LogAttrs(ctx, slog.LevelError, "attrs", slog.String("Correct", "correct"), slog.Any("Erroring", ErroringMarshal{}))
+			logger.LogAttrs(ctx, slog.LevelError, "attrs",
+				slog.Group("Group",
+					slog.Any("Erroring", ErroringMarshal{}),
+					slog.String("Correct", "correct"),
+					slog.Any("Map", map[string]any{"Erroring": ErroringMarshal{}}),
+				),
+				slog.String("After", "after"),
+			)
 			entries := capture.Entries()
 			received := entries[0]
 			err := errs.Err()
@@ -694,6 +4276,27 @@ func TestHandler(t *testing.T) {
 		})
 	})
 
+	t.Run("Config", func(t *testing.T) {
+		var capture slogtest.Capture[map[string]any]
+		h := baggagelabels.NewHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+			ProjectID: "jectpro",
+		}), nil)
+		logger, _ := slogtest.NewWithErrorHandler(h)
+
+		var inner slog.Handler = logger.Handler()
+		for {
+			w, ok := inner.(interface{ Unwrap() slog.Handler })
+			if !ok {
+				break
+			}
+			inner = w.Unwrap()
+		}
+		received := inner.(*slogdriver.Handler).Config()
+
+		require.Equal(t, "jectpro", received.ProjectID)
+		require.Equal(t, slogdriver.FieldMessage, received.MessageKey)
+	})
+
 	t.Run("Writer error", func(t *testing.T) {
 		ctx := context.Background()
 		var w ErrorWriter
@@ -704,6 +4307,137 @@ func TestHandler(t *testing.T) {
 
 		require.Error(t, err)
 	})
+
+	t.Run("short writes", func(t *testing.T) {
+		type Entry struct {
+			Message string `json:"message"`
+			Val     string
+		}
+
+		ctx := context.Background()
+		w := &ShortWriter{Max: 7}
+		logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(w, slogdriver.Config{}))
+		expected := Entry{"short", "some longer value"}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "short", slog.String("Val", "some longer value"))
+		var received Entry
+		err := errors.Join(errs.Err(), json.Unmarshal(w.Buf, &received))
+
+		require.NoError(t, err)
+		require.Equal(t, expected, received)
+		require.Equal(t, byte('\n'), w.Buf[len(w.Buf)-1])
+	})
+
+	t.Run("RecordSeparator", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			max      int
+			expected string
+		}{
+			{"whole writes", 1 << 10, "\x1e{\"message\":\"first\",\"severity\":300}\n\x1e{\"message\":\"second\",\"severity\":300}\n"},
+			{"short writes", 7, "\x1e{\"message\":\"first\",\"severity\":300}\n\x1e{\"message\":\"second\",\"severity\":300}\n"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				w := &ShortWriter{Max: tt.max}
+				h := slogdriver.NewHandler(w, slogdriver.Config{
+					OmitTimestamp:   true,
+					RecordSeparator: 0x1e,
+				})
+
+				var errs []error
+				for _, msg := range []string{"first", "second"} {
+					r := slog.NewRecord(time.Time{}, slog.LevelInfo, msg, 0)
+					errs = append(errs, h.Handle(ctx, r))
+				}
+				received := string(w.Buf)
+
+				require.NoError(t, errors.Join(errs...))
+				require.Equal(t, tt.expected, received)
+			})
+		}
+	})
+
+	t.Run("FlushInterval", func(t *testing.T) {
+		ctx := context.Background()
+		var w SyncWriter
+		h := slogdriver.NewHandler(&w, slogdriver.Config{FlushInterval: time.Millisecond})
+		logger, errs := slogtest.NewWithErrorHandler(h)
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "sync")
+		for deadline := time.Now().Add(5 * time.Second); w.Syncs() < 3 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		synced := w.Syncs()
+		closeErr := h.Close()
+		closed := w.Syncs()
+		time.Sleep(10 * time.Millisecond)
+		afterClose := w.Syncs()
+
+		require.NoError(t, errs.Err())
+		require.NoError(t, closeErr)
+		require.Equal(t, true, synced >= 3)
+		require.Equal(t, true, closed > synced)
+		require.Equal(t, closed, afterClose)
+		require.NoError(t, h.Close())
+	})
+
+	t.Run("FlushInterval without Sync", func(t *testing.T) {
+		var w IgnoreWriter
+		h := slogdriver.NewHandler(&w, slogdriver.Config{FlushInterval: time.Millisecond})
+
+		err := h.Close()
+
+		require.NoError(t, err)
+	})
+
+	t.Run("OnError", func(t *testing.T) {
+		ctx := context.Background()
+		var w ErrorWriter
+		var received []error
+		logger := slog.New(slogdriver.NewHandler(&w, slogdriver.Config{
+			OnError: func(err error) {
+				received = append(received, err)
+			},
+		}))
+
+		logger.LogAttrs(ctx, slog.LevelError, "write error")
+
+		require.Equal(t, 1, len(received))
+		require.Equal(t, "error writing", received[0].Error())
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		ctx := context.Background()
+		var w SwitchWriter
+		h := slogdriver.NewHandler(&w, slogdriver.Config{
+			Level: slog.LevelDebug,
+		})
+		logger := slog.New(h)
+		derived := logger.With(slog.String("foo", "bar"))
+		expected := slogdriver.Stats{
+			Debug:       1,
+			Info:        2,
+			Warn:        1,
+			Error:       1,
+			Critical:    1,
+			WriteErrors: 1,
+		}
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "debug")
+		logger.LogAttrs(ctx, slog.LevelInfo, "info")
+		derived.LogAttrs(ctx, slog.LevelInfo, "info")
+		derived.LogAttrs(ctx, slog.LevelWarn, "warn")
+		logger.LogAttrs(ctx, slog.LevelError, "error")
+		logger.LogAttrs(ctx, slogdriver.LevelCritical, "critical")
+		w.Fail = true
+		logger.LogAttrs(ctx, slog.LevelError, "dropped")
+		received := h.Stats()
+
+		require.Equal(t, expected, received)
+	})
 }
 
 func Benchmark(b *testing.B) {
@@ -727,6 +4461,118 @@ func Benchmark(b *testing.B) {
 	})
 }
 
+func BenchmarkMap(b *testing.B) {
+	ctx := context.Background()
+	w := &IgnoreWriter{}
+	level := slog.Level(-1e6)
+	slogdriverLogger := slog.New(slogdriver.NewHandler(w, slogdriver.Config{
+		Level: level,
+	}))
+	jsonLogger := slog.New(NewCloudLoggingJSONHandler(w, level))
+	meta := map[string]any{
+		"method": "GET",
+		"path":   "/hello",
+		"status": 200,
+		"cached": false,
+		"headers": map[string]string{
+			"Accept":     "application/json",
+			"User-Agent": "benchmark",
+		},
+	}
+
+	b.Run("slogdriver", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			slogdriverLogger.LogAttrs(ctx, slog.LevelInfo, "hello world", slog.Any("meta", meta))
+		}
+	})
+
+	b.Run("cloud logging JSONHandler", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			jsonLogger.LogAttrs(ctx, slog.LevelInfo, "hello world", slog.Any("meta", meta))
+		}
+	})
+}
+
+func BenchmarkRealistic(b *testing.B) {
+	w := &IgnoreWriter{}
+	level := slog.Level(-1e6)
+	slogdriverLogger := slog.New(slogdriver.NewHandler(w, slogdriver.Config{
+		ProjectID: "jectpro",
+		Level:     level,
+	}))
+	jsonLogger := slog.New(NewCloudLoggingJSONHandler(w, level))
+	ctx := slogdriver.WithTrace(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true)
+	ctx = slogdriver.AddLabels(ctx,
+		slogdriver.NewLabel("service", "benchmark"),
+		slogdriver.NewLabel("tenant", "acme"),
+	)
+	attrs := func() []slog.Attr {
+		return []slog.Attr{
+			slog.String("method", "GET"),
+			slog.Int("status", 200),
+			slog.Bool("cached", false),
+			slog.Float64("ratio", 0.75),
+			slog.Duration("elapsed", 1500*time.Millisecond),
+			slog.Group("user",
+				slog.String("id", "123"),
+				slog.String("role", "admin"),
+			),
+		}
+	}
+
+	b.Run("slogdriver", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			slogdriverLogger.LogAttrs(ctx, slog.LevelInfo, "hello world", attrs()...)
+		}
+	})
+
+	b.Run("cloud logging JSONHandler", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			jsonLogger.LogAttrs(ctx, slog.LevelInfo, "hello world", append(attrs(),
+				slog.String(slogdriver.FieldTrace, "projects/jectpro/traces/4bf92f3577b34da6a3ce929d0e0e4736"),
+				slog.String(slogdriver.FieldSpanID, "00f067aa0ba902b7"),
+				slog.Bool(slogdriver.FieldTraceSampled, true),
+				slog.Group(slogdriver.FieldLabels,
+					slog.String("service", "benchmark"),
+					slog.String("tenant", "acme"),
+				),
+			)...)
+		}
+	})
+}
+
+func BenchmarkWithChain(b *testing.B) {
+	ctx := context.Background()
+	w := &IgnoreWriter{}
+	logger := slog.New(slogdriver.NewHandler(w, slogdriver.Config{}))
+	attrs := make([]slog.Attr, 16)
+	for i := range attrs {
+		attrs[i] = slog.Int("attr"+strconv.Itoa(i), i)
+	}
+	chained := logger
+	for _, attr := range attrs {
+		chained = chained.With(attr)
+	}
+
+	b.Run("chained", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			chained.LogAttrs(ctx, slog.LevelInfo, "hello world")
+		}
+	})
+
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			logger.LogAttrs(ctx, slog.LevelInfo, "hello world", attrs...)
+		}
+	})
+}
+
 func NewCloudLoggingJSONHandler(w io.Writer, level slog.Leveler) *slog.JSONHandler {
 	const (
 		fieldMessage        = "message"
@@ -793,6 +4639,50 @@ func (fn ValuerFunc) LogValue() slog.Value {
 	return fn()
 }
 
+// StackTraceError mimics the errors of github.com/pkg/errors, exposing the
+// stack trace as a slice of program counters of a named type.
+type StackTraceError struct {
+	Message string
+	Frames  []StackFrame
+}
+
+type StackFrame uintptr
+
+func (err *StackTraceError) Error() string {
+	return err.Message
+}
+
+func (err *StackTraceError) StackTrace() []StackFrame {
+	return err.Frames
+}
+
+type PointerValuer struct {
+	Value string
+}
+
+func (v *PointerValuer) LogValue() slog.Value {
+	return slog.StringValue("resolved " + v.Value)
+}
+
+// SelfValuer resolves to a copy of itself, which doesn't implement
+// slog.LogValuer, as only its pointer does.
+type SelfValuer struct {
+	Value string
+}
+
+func (v *SelfValuer) LogValue() slog.Value {
+	return slog.AnyValue(*v)
+}
+
+type LabelValuer struct {
+	Key   string
+	Value string
+}
+
+func (v *LabelValuer) LogValue() slog.Value {
+	return slog.AnyValue(slogdriver.NewLabel(v.Key, v.Value))
+}
+
 type JSONError struct {
 	Message string
 }
@@ -834,18 +4724,89 @@ func (*ErrorWriter) Write(data []byte) (n int, err error) {
 	return 0, fmt.Errorf("error writing")
 }
 
+type ShortWriter struct {
+	Max int
+	Buf []byte
+}
+
+func (w *ShortWriter) Write(data []byte) (n int, err error) {
+	n = min(len(data), w.Max)
+	w.Buf = append(w.Buf, data[:n]...)
+	return n, nil
+}
+
+type SwitchWriter struct {
+	Fail bool
+}
+
+func (w *SwitchWriter) Write(data []byte) (n int, err error) {
+	if w.Fail {
+		return 0, fmt.Errorf("error writing")
+	}
+	return len(data), nil
+}
+
+type SyncWriter struct {
+	IgnoreWriter
+	syncs atomic.Int64
+}
+
+func (w *SyncWriter) Sync() error {
+	w.syncs.Add(1)
+	return nil
+}
+
+func (w *SyncWriter) Syncs() int64 {
+	return w.syncs.Load()
+}
+
+type PointerError struct {
+	Message string
+}
+
+func (e *PointerError) Error() string {
+	return e.Message
+}
+
 type ErroringMarshal struct{}
 
 func (ErroringMarshal) MarshalJSON() ([]byte, error) {
 	return nil, fmt.Errorf("cannot be marshaled")
 }
 
+// rawAnyValue returns a slog.Value of slog.KindAny holding v as is, bypassing
+// the normalization done by slog.AnyValue.
+func rawAnyValue(v any) slog.Value {
+	type FakeValue struct {
+		num uint64
+		any any
+	}
+	return *(*slog.Value)(unsafe.Pointer(&FakeValue{any: v}))
+}
+
+func logFromLibrary(logger *slog.Logger) {
+	logger.Info("library")
+}
+
+type libraryLogger struct {
+	logger *slog.Logger
+}
+
+func (l *libraryLogger) Log() {
+	l.logger.Info("library")
+}
+
 func getPC() uintptr {
 	var pcs [1]uintptr
 	runtime.Callers(2, pcs[:])
 	return pcs[0]
 }
 
+func hasKey[K comparable, V any](m map[K]V, key K) bool {
+	_, ok := m[key]
+	return ok
+}
+
 func vptr[T any](v T) *T {
 	return &v
 }
@@ -0,0 +1,114 @@
+// Package httplog provides an HTTP middleware that logs an entry for each
+// request with the httpRequest field populated.
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+package httplog
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Config is the configuration for the middleware.
+type Config struct {
+	// Level is the level of the logged request entries. Defaults to
+	// slog.LevelInfo.
+	Level slog.Leveler
+
+	// Message is the message of the logged request entries. Defaults to
+	// "request".
+	Message string
+
+	// Now returns the current time, used for measuring the latency of the
+	// requests. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Middleware returns a middleware that logs an entry to the logger after each
+// request has been served.
+func Middleware(logger *slog.Logger, config Config) func(http.Handler) http.Handler {
+	if config.Level == nil {
+		config.Level = slog.LevelInfo
+	}
+	if config.Message == "" {
+		config.Message = "request"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := config.Now()
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+			latency := config.Now().Sub(start)
+
+			logger.LogAttrs(r.Context(), config.Level.Level(), config.Message, slog.Group(fieldHTTPRequest,
+				slog.String("requestMethod", r.Method),
+				slog.String("requestUrl", r.URL.String()),
+				slog.Int("status", rw.status),
+				slog.String("responseSize", strconv.FormatInt(rw.size, 10)),
+				slog.String("userAgent", r.UserAgent()),
+				slog.String("remoteIp", r.RemoteAddr),
+				slog.String("referer", r.Referer()),
+				slog.String("protocol", r.Proto),
+				slog.String("latency", formatLatency(latency)),
+			))
+		})
+	}
+}
+
+// formatLatency formats the duration as the JSON representation of a
+// protobuf Duration, e.g. "1.500s".
+func formatLatency(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	seconds := int64(d / time.Second)
+	nanos := int64(d % time.Second)
+	switch {
+	case nanos == 0:
+		return fmt.Sprintf("%s%ds", sign, seconds)
+	case nanos%int64(time.Millisecond) == 0:
+		return fmt.Sprintf("%s%d.%03ds", sign, seconds, nanos/int64(time.Millisecond))
+	case nanos%int64(time.Microsecond) == 0:
+		return fmt.Sprintf("%s%d.%06ds", sign, seconds, nanos/int64(time.Microsecond))
+	}
+	return fmt.Sprintf("%s%d.%09ds", sign, seconds, nanos)
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use with
+// http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+const fieldHTTPRequest = "httpRequest"
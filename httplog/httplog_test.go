@@ -0,0 +1,121 @@
+package httplog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/httplog"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestMiddleware(t *testing.T) {
+	type HTTPRequest struct {
+		RequestMethod string `json:"requestMethod"`
+		RequestURL    string `json:"requestUrl"`
+		Status        int    `json:"status"`
+		ResponseSize  string `json:"responseSize"`
+		UserAgent     string `json:"userAgent"`
+		Latency       string `json:"latency"`
+	}
+
+	type Entry struct {
+		Message     string      `json:"message"`
+		Severity    string      `json:"severity"`
+		HTTPRequest HTTPRequest `json:"httpRequest"`
+	}
+
+	tests := []struct {
+		name     string
+		step     time.Duration
+		handler  http.HandlerFunc
+		expected Entry
+	}{
+		{
+			"implicit status",
+			1500 * time.Millisecond,
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			},
+			Entry{
+				Message:  "request",
+				Severity: "INFO",
+				HTTPRequest: HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "/foo?bar=1",
+					Status:        http.StatusOK,
+					ResponseSize:  "5",
+					UserAgent:     "test-agent",
+					Latency:       "1.500s",
+				},
+			},
+		},
+		{
+			"explicit status",
+			2 * time.Second,
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+			Entry{
+				Message:  "request",
+				Severity: "INFO",
+				HTTPRequest: HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "/foo?bar=1",
+					Status:        http.StatusTeapot,
+					ResponseSize:  "0",
+					UserAgent:     "test-agent",
+					Latency:       "2s",
+				},
+			},
+		},
+		{
+			"sub-millisecond latency",
+			1500 * time.Nanosecond,
+			func(w http.ResponseWriter, r *http.Request) {},
+			Entry{
+				Message:  "request",
+				Severity: "INFO",
+				HTTPRequest: HTTPRequest{
+					RequestMethod: "GET",
+					RequestURL:    "/foo?bar=1",
+					Status:        http.StatusOK,
+					ResponseSize:  "0",
+					UserAgent:     "test-agent",
+					Latency:       "0.000001500s",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capture slogtest.Capture[Entry]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				SeverityFormat: slogdriver.SeverityFormatString,
+			}))
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			middleware := httplog.Middleware(logger, httplog.Config{
+				Now: func() time.Time {
+					t := now
+					now = now.Add(tt.step)
+					return t
+				},
+			})
+			r := httptest.NewRequest(http.MethodGet, "/foo?bar=1", nil).WithContext(context.Background())
+			r.Header.Set("User-Agent", "test-agent")
+
+			middleware(tt.handler).ServeHTTP(httptest.NewRecorder(), r)
+			entries := capture.Entries()
+			received := entries[0]
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package slogdriver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	insertIDPrefix  = newInsertIDPrefix()
+	insertIDCounter atomic.Uint64
+)
+
+func newInsertIDPrefix() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// nextInsertID returns a process-unique insertId. The insertIds sort in the
+// order they were generated, as the counter is zero-padded to a fixed width.
+func nextInsertID() string {
+	const width = 20 // digits in max uint64
+	n := insertIDCounter.Add(1)
+	buf := make([]byte, 0, len(insertIDPrefix)+1+width)
+	buf = append(buf, insertIDPrefix...)
+	buf = append(buf, '-')
+	digits := strconv.AppendUint(nil, n, 10)
+	for i := len(digits); i < width; i++ {
+		buf = append(buf, '0')
+	}
+	return string(append(buf, digits...))
+}
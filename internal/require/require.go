@@ -169,7 +169,7 @@ func equalMaps(tb testing.TB, expected, received any) error {
 			}
 		}
 	}
-	riter := em.MapRange()
+	riter := rm.MapRange()
 	for riter.Next() {
 		i := riter.Key()
 		k := i.Interface()
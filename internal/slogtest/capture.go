@@ -1,31 +1,49 @@
 package slogtest
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"sync"
 )
 
 // Capture is an io.Writer that unmarshals the written data into entries of
-// type T, to be later retrieved with Entries(). Written buffers must be valid
-// JSON by themselves, and if the unmarshaling errors, Write will return an
-// error.
+// type T, to be later retrieved with Entries(). Written buffers must consist
+// of whole JSON values, each of which is captured as a separate entry, and if
+// the unmarshaling errors, Write will return an error without capturing any
+// of the entries of the buffer. The written data is also kept as is, to be
+// retrieved with Raw().
 type Capture[T any] struct {
 	m       sync.Mutex
 	entries []T
+	raw     []byte
 }
 
 // Write implements io.Writer.
 func (c *Capture[T]) Write(data []byte) (n int, err error) {
 	n = len(data)
 
-	var entry T
-	if err = json.Unmarshal(data, &entry); err != nil {
-		return n, err
+	var entries []T
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var entry T
+		if err = dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			break
+		}
+		entries = append(entries, entry)
 	}
 
 	c.m.Lock()
 	defer c.m.Unlock()
-	c.entries = append(c.entries, entry)
+	c.raw = append(c.raw, data...)
+	if err != nil {
+		return n, err
+	}
+	c.entries = append(c.entries, entries...)
 
 	return n, nil
 }
@@ -36,3 +54,11 @@ func (c *Capture[T]) Entries() []T {
 	defer c.m.Unlock()
 	return c.entries
 }
+
+// Raw returns the captured data as it was written, including the data that
+// failed to unmarshal.
+func (c *Capture[T]) Raw() []byte {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.raw
+}
@@ -0,0 +1,40 @@
+package slogtest_test
+
+import (
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestCapture(t *testing.T) {
+	type Entry struct {
+		Foo string
+	}
+
+	t.Run("concatenated values", func(t *testing.T) {
+		var capture slogtest.Capture[Entry]
+		data := []byte(`{"Foo":"a"}` + "\n" + `{"Foo":"b"}{"Foo":"c"}` + "\n")
+		expected := []Entry{{"a"}, {"b"}, {"c"}}
+
+		n, err := capture.Write(data)
+		received := capture.Entries()
+
+		require.NoError(t, err)
+		require.Equal(t, len(data), n)
+		require.Equal(t, expected, received)
+		require.Equal(t, string(data), string(capture.Raw()))
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		var capture slogtest.Capture[Entry]
+		data := []byte(`{"Foo":"a"}{"Foo":`)
+
+		_, err := capture.Write(data)
+		received := capture.Entries()
+
+		require.Error(t, err)
+		require.Equal(t, 0, len(received))
+		require.Equal(t, string(data), string(capture.Raw()))
+	})
+}
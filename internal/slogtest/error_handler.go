@@ -56,6 +56,11 @@ func (h *ErrorHandler) WithGroup(name string) slog.Handler {
 	}
 }
 
+// Unwrap returns the inner handler.
+func (h *ErrorHandler) Unwrap() slog.Handler {
+	return h.inner
+}
+
 // Err returns the captured error(s).
 func (h *ErrorHandler) Err() error {
 	return h.errorCapture.Err()
@@ -0,0 +1,99 @@
+package slogtest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// OpKind is the kind of an Op recorded by a Recorder.
+type OpKind int
+
+const (
+	// OpWithAttrs is a call to WithAttrs.
+	OpWithAttrs OpKind = iota
+	// OpWithGroup is a call to WithGroup.
+	OpWithGroup
+	// OpHandle is a call to Handle.
+	OpHandle
+)
+
+// Op is an operation recorded by a Recorder.
+type Op struct {
+	Kind OpKind
+	// Attrs are the attrs passed to WithAttrs.
+	Attrs []slog.Attr
+	// Group is the name passed to WithGroup.
+	Group string
+	// Record is the record passed to Handle.
+	Record slog.Record
+	// Scope contains the WithAttrs and WithGroup operations that produced
+	// the handler the operation was called on.
+	Scope []Op
+}
+
+// Recorder is a slog.Handler that records the operations called on it and
+// the handlers derived from it, to be later retrieved with Ops(). Unlike
+// Capture, it sees the calls instead of the serialized output.
+type Recorder struct {
+	log   *opLog
+	scope []Op
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{log: &opLog{}}
+}
+
+// Enabled implements slog.Handler.
+func (h *Recorder) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler.
+func (h *Recorder) Handle(ctx context.Context, r slog.Record) error {
+	h.log.add(Op{Kind: OpHandle, Record: r.Clone(), Scope: h.scope})
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(Op{Kind: OpWithAttrs, Attrs: slices.Clone(attrs)})
+}
+
+// WithGroup implements slog.Handler.
+func (h *Recorder) WithGroup(name string) slog.Handler {
+	return h.with(Op{Kind: OpWithGroup, Group: name})
+}
+
+// Ops returns the recorded operations in the order they were called.
+func (h *Recorder) Ops() []Op {
+	return h.log.get()
+}
+
+func (h *Recorder) with(op Op) *Recorder {
+	op.Scope = h.scope
+	h.log.add(op)
+	return &Recorder{
+		log:   h.log,
+		scope: append(slices.Clip(h.scope), op),
+	}
+}
+
+type opLog struct {
+	m   sync.Mutex
+	ops []Op
+}
+
+func (l *opLog) add(op Op) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.ops = append(l.ops, op)
+}
+
+func (l *opLog) get() []Op {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return slices.Clone(l.ops)
+}
@@ -0,0 +1,44 @@
+package slogtest_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	recorder := slogtest.NewRecorder()
+	logger := slog.New(recorder)
+	derived := logger.With("foo", "bar").WithGroup("group")
+
+	logger.InfoContext(ctx, "root", "a", 1)
+	derived.WarnContext(ctx, "derived")
+	ops := recorder.Ops()
+
+	require.Equal(t, 4, len(ops))
+
+	require.Equal(t, slogtest.OpWithAttrs, ops[0].Kind)
+	require.Equal(t, 1, len(ops[0].Attrs))
+	require.Equal(t, "foo", ops[0].Attrs[0].Key)
+	require.Equal(t, 0, len(ops[0].Scope))
+
+	require.Equal(t, slogtest.OpWithGroup, ops[1].Kind)
+	require.Equal(t, "group", ops[1].Group)
+	require.Equal(t, 1, len(ops[1].Scope))
+
+	require.Equal(t, slogtest.OpHandle, ops[2].Kind)
+	require.Equal(t, "root", ops[2].Record.Message)
+	require.Equal(t, 1, ops[2].Record.NumAttrs())
+	require.Equal(t, 0, len(ops[2].Scope))
+
+	require.Equal(t, slogtest.OpHandle, ops[3].Kind)
+	require.Equal(t, "derived", ops[3].Record.Message)
+	require.Equal(t, slog.LevelWarn, ops[3].Record.Level)
+	require.Equal(t, 2, len(ops[3].Scope))
+	require.Equal(t, slogtest.OpWithAttrs, ops[3].Scope[0].Kind)
+	require.Equal(t, "group", ops[3].Scope[1].Group)
+}
@@ -0,0 +1,32 @@
+package slogdriver
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a key to snake_case, e.g. "HTTPRequestID" becomes
+// "http_request_id". Meant to be used as Config.KeyTransform.
+func SnakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 && runes[i-1] != '_' && runes[i-1] != '-' && runes[i-1] != ' ' {
+				prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package slogdriver_test
+
+import (
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"", ""},
+		{"simple", "simple"},
+		{"camelCase", "camel_case"},
+		{"PascalCase", "pascal_case"},
+		{"HTTPRequestID", "http_request_id"},
+		{"userID", "user_id"},
+		{"CustomPrepared1", "custom_prepared1"},
+		{"Value2Key", "value2_key"},
+		{"already_snake", "already_snake"},
+		{"Mixed_Case", "mixed_case"},
+		{"kebab-case", "kebab_case"},
+		{"with space", "with_space"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			received := slogdriver.SnakeCase(tt.key)
+
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
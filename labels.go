@@ -2,7 +2,29 @@ package slogdriver
 
 import "context"
 
+// LabelStyle defines how the labels of the entries are written.
+type LabelStyle int
+
+const (
+	// LabelStyleNested writes the labels as a record in the
+	// logging.googleapis.com/labels field, as expected by the logging agent.
+	LabelStyleNested LabelStyle = iota
+	// LabelStylePrefixed writes the labels as top-level fields with the keys
+	// prefixed with "labels.", e.g. "labels.key".
+	LabelStylePrefixed
+)
+
+const labelPrefix = "labels."
+
 // Label represents a key-value string pair.
+//
+// Attrs with Label values, e.g. slog.Any("label", NewLabel("key", "value")),
+// are added to the labels of the entry instead of the payload.
+//
+// The labels of an entry are global to it, so they're always written at the
+// root of the entry, regardless of the groups opened with WithGroup or the
+// groups the Label attrs are in, and their keys are never prefixed with the
+// group names.
 type Label struct {
 	Key   string
 	Value string
@@ -20,10 +42,43 @@ func NewLabel(key, value string) Label {
 func AddLabels(ctx context.Context, labels ...Label) context.Context {
 	return context.WithValue(ctx, labelsContextKeyT{}, &labelContainer{
 		Labels: labels,
-		Parent: labelsFromContext(ctx),
+		Parent: labelsFromContext(ctx).inherited(),
 	})
 }
 
+// AddLocalLabels returns a new Context with additional labels to be used only
+// in the log entries produced using that exact context.
+//
+// Go contexts can't tell a derived Context from the one it was derived from,
+// so the local labels are dropped only when labels are added to a derived
+// Context with AddLabels, AddLabelsMap or AddLocalLabels. A Context derived in
+// other ways, e.g. with context.WithCancel, still uses the local labels.
+func AddLocalLabels(ctx context.Context, labels ...Label) context.Context {
+	return context.WithValue(ctx, labelsContextKeyT{}, &labelContainer{
+		Labels: labels,
+		Parent: labelsFromContext(ctx).inherited(),
+		Local:  true,
+	})
+}
+
+// AddLabelsMap returns a new Context with additional labels from a map to be
+// used in the log entries produced using that context. As with AddLabels, the
+// labels override any labels with the same keys in the parent Context.
+func AddLabelsMap(ctx context.Context, labels map[string]string) context.Context {
+	l := make([]Label, 0, len(labels))
+	for _, key := range sortedKeys(labels) {
+		l = append(l, NewLabel(key, labels[key]))
+	}
+	return AddLabels(ctx, l...)
+}
+
+// ResetLabels returns a new Context without any of the labels added to the
+// parent Context. Labels added to the returned Context with AddLabels are
+// still used.
+func ResetLabels(ctx context.Context) context.Context {
+	return context.WithValue(ctx, labelsContextKeyT{}, &labelContainer{})
+}
+
 func labelsFromContext(ctx context.Context) *labelContainer {
 	v, _ := ctx.Value(labelsContextKeyT{}).(*labelContainer)
 	return v
@@ -34,6 +89,16 @@ type labelsContextKeyT struct{}
 type labelContainer struct {
 	Labels []Label
 	Parent *labelContainer
+	Local  bool
+}
+
+// inherited returns the closest container that isn't local, for use as the
+// parent of the labels added to a derived Context.
+func (l *labelContainer) inherited() *labelContainer {
+	for l != nil && l.Local {
+		l = l.Parent
+	}
+	return l
 }
 
 func (l *labelContainer) Iterate(f func(Label)) {
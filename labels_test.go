@@ -23,6 +23,24 @@ func TestLabels(t *testing.T) {
 
 		requireEqualSlices(t, expected, received)
 	})
+
+	t.Run("reset labels", func(t *testing.T) {
+		ctx := context.Background()
+		l1 := NewLabel("key1", "value1")
+		l2 := NewLabel("key2", "value2")
+		l3 := NewLabel("key3", "value3")
+		ctx = AddLabels(ctx, l1, l2)
+		ctx = ResetLabels(ctx)
+		ctx = AddLabels(ctx, l3)
+		expected := []Label{l3}
+		received := make([]Label, 0, len(expected))
+
+		labelsFromContext(ctx).Iterate(func(l Label) {
+			received = append(received, l)
+		})
+
+		requireEqualSlices(t, expected, received)
+	})
 }
 
 func requireEqualSlices[T comparable](tb testing.TB, expected, received []T) {
@@ -0,0 +1,23 @@
+module github.com/jussi-kalliokoski/slogdriver/logentry
+
+go 1.23.0
+
+require (
+	cloud.google.com/go/logging v1.13.0
+	github.com/jussi-kalliokoski/slogdriver v0.0.0
+	google.golang.org/genproto v0.0.0-20250603155806-513f23925822
+	google.golang.org/genproto/googleapis/api v0.0.0-20250528174236-200df99c418a
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	cloud.google.com/go/longrunning v0.6.7 // indirect
+	github.com/jussi-kalliokoski/goldjson v1.0.0 // indirect
+	golang.org/x/net v0.40.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
+	google.golang.org/grpc v1.72.1 // indirect
+)
+
+replace github.com/jussi-kalliokoski/slogdriver => ../
@@ -0,0 +1,122 @@
+// Package logentry converts slog records into Cloud Logging LogEntry
+// protobuf messages, for writing to Cloud Logging with the v2 API.
+//
+// The package is a separate module to keep the protobuf dependencies out of
+// slogdriver.
+package logentry
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/api"
+)
+
+// Converter converts slog records into LogEntries, with the same fields as a
+// slogdriver.Handler with its config would write: the message and the attrs
+// make up the JSON payload, and the severity, the trace, the labels, the
+// source location, the HTTP request, the insertId and the monitored resource
+// are extracted into their own fields.
+//
+// A Converter is safe for concurrent use.
+type Converter struct {
+	converters sync.Pool
+}
+
+// New returns a new Converter for the config.
+func New(config slogdriver.Config) *Converter {
+	c := &Converter{}
+	c.converters.New = func() any {
+		conv := &converter{}
+		conv.h = api.NewHandler(api.LoggerFunc(func(e api.Entry) {
+			conv.entry = fromEntry(e)
+		}), config)
+		return conv
+	}
+	return c
+}
+
+// ToLogEntry converts the record into a LogEntry.
+//
+// The errors encountered while handling the record, e.g. an httpRequest
+// group that isn't a valid HttpRequest, are passed to Config.OnError. The
+// invalid fields are kept in the payload.
+func (c *Converter) ToLogEntry(ctx context.Context, r slog.Record) *loggingpb.LogEntry {
+	conv := c.converters.Get().(*converter)
+	defer c.converters.Put(conv)
+	_ = conv.h.Handle(ctx, r)
+	entry := conv.entry
+	conv.entry = nil
+	return entry
+}
+
+// converter is a Handler that stores the entry of the last handled record,
+// pooled so that the concurrent conversions each get their own.
+type converter struct {
+	h     *slogdriver.Handler
+	entry *loggingpb.LogEntry
+}
+
+func fromEntry(e api.Entry) *loggingpb.LogEntry {
+	entry := &loggingpb.LogEntry{
+		InsertId:     e.InsertID,
+		Severity:     ltype.LogSeverity(e.Severity),
+		Labels:       e.Labels,
+		Trace:        e.Trace,
+		SpanId:       e.SpanID,
+		TraceSampled: e.TraceSampled,
+	}
+	if !e.Timestamp.IsZero() {
+		entry.Timestamp = timestamppb.New(e.Timestamp)
+	}
+	// the payload only contains the types that structpb supports
+	if payload, err := structpb.NewStruct(e.Payload); err == nil {
+		entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}
+	}
+	if e.SourceLocation != nil {
+		entry.SourceLocation = &loggingpb.LogEntrySourceLocation{
+			File:     e.SourceLocation.File,
+			Line:     e.SourceLocation.Line,
+			Function: e.SourceLocation.Function,
+		}
+	}
+	if e.HTTPRequest != nil {
+		entry.HttpRequest = httpRequest(e.HTTPRequest)
+	}
+	if e.Resource != nil {
+		entry.Resource = &monitoredres.MonitoredResource{
+			Type:   e.Resource.Type,
+			Labels: e.Resource.Labels,
+		}
+	}
+	return entry
+}
+
+func httpRequest(req *api.HTTPRequest) *ltype.HttpRequest {
+	return &ltype.HttpRequest{
+		RequestMethod:                  req.RequestMethod,
+		RequestUrl:                     req.RequestURL,
+		RequestSize:                    req.RequestSize,
+		Status:                         int32(req.Status),
+		ResponseSize:                   req.ResponseSize,
+		UserAgent:                      req.UserAgent,
+		RemoteIp:                       req.RemoteIP,
+		ServerIp:                       req.ServerIP,
+		Referer:                        req.Referer,
+		Latency:                        durationpb.New(req.Latency),
+		CacheLookup:                    req.CacheLookup,
+		CacheHit:                       req.CacheHit,
+		CacheValidatedWithOriginServer: req.CacheValidatedWithOriginServer,
+		CacheFillBytes:                 req.CacheFillBytes,
+		Protocol:                       req.Protocol,
+	}
+}
@@ -0,0 +1,154 @@
+package logentry_test
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ltype "google.golang.org/genproto/googleapis/logging/type"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/logentry"
+)
+
+func TestToLogEntry(t *testing.T) {
+	t.Run("special fields", func(t *testing.T) {
+		ctx := context.Background()
+		ctx = slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true}.Context(ctx)
+		ctx = slogdriver.AddLabels(ctx, slogdriver.NewLabel("foo", "bar"))
+		now := time.Date(2023, 6, 15, 19, 24, 13, 123456789, time.UTC)
+		var pcs [1]uintptr
+		runtime.Callers(1, pcs[:])
+		r := slog.NewRecord(now, slog.LevelWarn, "hello", pcs[0])
+
+		received := logentry.New(slogdriver.Config{ProjectID: "jectpro"}).ToLogEntry(ctx, r)
+
+		require.Equal(t, now, received.GetTimestamp().AsTime())
+		require.Equal(t, ltype.LogSeverity_WARNING, received.GetSeverity())
+		require.Equal(t, map[string]string{"foo": "bar"}, received.GetLabels())
+		require.Equal(t, "projects/jectpro/traces/abc", received.GetTrace())
+		require.Equal(t, "def", received.GetSpanId())
+		require.Equal(t, true, received.GetTraceSampled())
+		require.Equal(t, "github.com/jussi-kalliokoski/slogdriver/logentry_test.TestToLogEntry.func1", received.GetSourceLocation().GetFunction())
+		require.Equal(t, true, received.GetSourceLocation().GetLine() > 0)
+	})
+
+	t.Run("severity", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			level    slog.Level
+			expected ltype.LogSeverity
+		}{
+			{"debug", slog.LevelDebug, ltype.LogSeverity_DEBUG},
+			{"info", slog.LevelInfo, ltype.LogSeverity_INFO},
+			{"warn", slog.LevelWarn, ltype.LogSeverity_WARNING},
+			{"error", slog.LevelError, ltype.LogSeverity_ERROR},
+			{"critical", slogdriver.LevelCritical, ltype.LogSeverity_CRITICAL},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				r := slog.NewRecord(time.Now(), tt.level, "severity", 0)
+
+				received := logentry.New(slogdriver.Config{}).ToLogEntry(context.Background(), r)
+
+				require.Equal(t, tt.expected, received.GetSeverity())
+			})
+		}
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0)
+		r.AddAttrs(
+			slog.String("str", "abc"),
+			slog.Int64("num", 123),
+			slog.Group("group", slog.Bool("inner", true)),
+		)
+		expected := map[string]any{
+			"message": "hello",
+			"str":     "abc",
+			"num":     float64(123),
+			"group": map[string]any{
+				"inner": true,
+			},
+		}
+
+		received := logentry.New(slogdriver.Config{}).ToLogEntry(context.Background(), r)
+
+		require.Equal(t, expected, received.GetJsonPayload().AsMap())
+	})
+
+	t.Run("httpRequest", func(t *testing.T) {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "request", 0)
+		r.AddAttrs(slog.Group("httpRequest",
+			slog.String("requestMethod", "GET"),
+			slog.Int("status", 200),
+			slog.String("latency", "1.5s"),
+		))
+
+		received := logentry.New(slogdriver.Config{}).ToLogEntry(context.Background(), r)
+
+		require.Equal(t, "GET", received.GetHttpRequest().GetRequestMethod())
+		require.Equal(t, int32(200), received.GetHttpRequest().GetStatus())
+		require.Equal(t, 1500*time.Millisecond, received.GetHttpRequest().GetLatency().AsDuration())
+		require.Equal(t, map[string]any{"message": "request"}, received.GetJsonPayload().AsMap())
+	})
+
+	t.Run("insertId and resource", func(t *testing.T) {
+		ctx := slogdriver.WithResource(context.Background(), slogdriver.MonitoredResource{
+			Type:   "pubsub_topic",
+			Labels: map[string]string{"topic_id": "events"},
+		})
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "resource", 0)
+
+		received := logentry.New(slogdriver.Config{GenerateInsertID: true}).ToLogEntry(ctx, r)
+
+		require.Equal(t, true, received.GetInsertId() != "")
+		require.Equal(t, "pubsub_topic", received.GetResource().GetType())
+		require.Equal(t, map[string]string{"topic_id": "events"}, received.GetResource().GetLabels())
+		require.Equal(t, map[string]any{"message": "resource"}, received.GetJsonPayload().AsMap())
+	})
+
+	t.Run("invalid httpRequest", func(t *testing.T) {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "request", 0)
+		r.AddAttrs(slog.Group("httpRequest", slog.String("status", "not a number")))
+		var errs []error
+		expected := map[string]any{
+			"message":     "request",
+			"httpRequest": map[string]any{"status": "not a number"},
+		}
+
+		received := logentry.New(slogdriver.Config{
+			OnError: func(err error) { errs = append(errs, err) },
+		}).ToLogEntry(context.Background(), r)
+
+		require.Equal(t, 1, len(errs))
+		require.Equal(t, true, received.GetHttpRequest() == nil)
+		require.Equal(t, expected, received.GetJsonPayload().AsMap())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		c := logentry.New(slogdriver.Config{})
+		received := make([]string, 10)
+		var wg sync.WaitGroup
+
+		for i := range received {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r := slog.NewRecord(time.Now(), slog.LevelInfo, strconv.Itoa(i), 0)
+				received[i] = c.ToLogEntry(context.Background(), r).GetJsonPayload().AsMap()["message"].(string)
+			}()
+		}
+		wg.Wait()
+
+		for i, message := range received {
+			require.Equal(t, strconv.Itoa(i), message)
+		}
+	})
+}
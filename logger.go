@@ -0,0 +1,20 @@
+package slogdriver
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// NewLogger returns a new slog.Logger that writes the log entries to w with a
+// Handler using the config.
+func NewLogger(w io.Writer, config Config) *slog.Logger {
+	return slog.New(NewHandler(w, config))
+}
+
+// Default returns a new slog.Logger that writes the log entries of the
+// project to os.Stdout, where the logging agent of the GCP runtime picks them
+// up, with the default Config.
+func Default(projectID string) *slog.Logger {
+	return NewLogger(os.Stdout, Config{ProjectID: projectID})
+}
@@ -0,0 +1,62 @@
+package slogdriver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestNewLogger(t *testing.T) {
+	type Entry struct {
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+		Trace    string `json:"logging.googleapis.com/trace"`
+		Foo      string `json:"foo"`
+	}
+
+	ctx := slogdriver.Trace{ID: "abc"}.Context(context.Background())
+	var capture slogtest.Capture[Entry]
+	logger := slogdriver.NewLogger(&capture, slogdriver.Config{
+		ProjectID:      "jectpro",
+		SeverityFormat: slogdriver.SeverityFormatString,
+	})
+	expected := Entry{"hello", "WARNING", "projects/jectpro/traces/abc", "bar"}
+
+	logger.WarnContext(ctx, "hello", slog.String("foo", "bar"))
+	entries := capture.Entries()
+	received := entries[0]
+
+	require.Equal(t, expected, received)
+}
+
+func TestDefault(t *testing.T) {
+	type Entry struct {
+		Message  string `json:"message"`
+		Severity int    `json:"severity"`
+		Trace    string `json:"logging.googleapis.com/trace"`
+	}
+
+	ctx := slogdriver.Trace{ID: "abc"}.Context(context.Background())
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	os.Stdout = w
+	expected := Entry{"hello", 300, "projects/jectpro/traces/abc"}
+
+	slogdriver.Default("jectpro").InfoContext(ctx, "hello")
+	require.NoError(t, w.Close())
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	var received Entry
+	err = json.Unmarshal(data, &received)
+
+	require.NoError(t, err)
+	require.Equal(t, expected, received)
+}
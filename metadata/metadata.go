@@ -0,0 +1,65 @@
+// Package metadata detects configuration for slogdriver from the GCE metadata
+// server, which is also available on e.g. Cloud Run and GKE.
+//
+// See https://cloud.google.com/compute/docs/metadata/overview
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DetectProjectID returns the ID of the project the process is running in,
+// to be used as slogdriver.Config.ProjectID.
+//
+// The metadata server host can be overridden with the GCE_METADATA_HOST
+// environment variable. Unless the context has an earlier deadline, the
+// request times out after two seconds.
+func DetectProjectID(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host := os.Getenv(envHost)
+	if host == "" {
+		host = defaultHost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+pathProjectID, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata: unexpected status %d", res.StatusCode)
+	}
+
+	projectID := strings.TrimSpace(string(body))
+	if projectID == "" {
+		return "", fmt.Errorf("metadata: empty project ID")
+	}
+	return projectID, nil
+}
+
+const (
+	timeout         = 2 * time.Second
+	envHost         = "GCE_METADATA_HOST"
+	defaultHost     = "metadata.google.internal"
+	pathProjectID   = "/computeMetadata/v1/project/project-id"
+	maxResponseSize = 1 << 10
+)
@@ -0,0 +1,120 @@
+// Package propagation provides helpers for extracting slogdriver.Trace from
+// incoming trace context headers.
+//
+// Both the W3C Trace Context traceparent header and the legacy
+// X-Cloud-Trace-Context header are supported.
+package propagation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+)
+
+// TraceFromMetadata returns the Trace from incoming gRPC metadata, e.g. a
+// metadata.MD.
+//
+// The traceparent header is preferred over the x-cloud-trace-context header
+// when both are present. Returns false if neither contains a usable trace.
+func TraceFromMetadata(md map[string][]string) (slogdriver.Trace, bool) {
+	for _, v := range md[metadataTraceparent] {
+		if trace, ok := ParseTraceparent(v); ok {
+			return trace, true
+		}
+	}
+	for _, v := range md[metadataCloudTraceContext] {
+		if trace, ok := ParseCloudTraceContext(v); ok {
+			return trace, true
+		}
+	}
+	return slogdriver.Trace{}, false
+}
+
+// TraceFromRequest returns the Trace from the headers of an incoming HTTP
+// request.
+//
+// The traceparent header is preferred over the X-Cloud-Trace-Context header
+// when both are present. Returns false if neither contains a usable trace.
+func TraceFromRequest(r *http.Request) (slogdriver.Trace, bool) {
+	for _, v := range r.Header.Values(metadataTraceparent) {
+		if trace, ok := ParseTraceparent(v); ok {
+			return trace, true
+		}
+	}
+	for _, v := range r.Header.Values(metadataCloudTraceContext) {
+		if trace, ok := ParseCloudTraceContext(v); ok {
+			return trace, true
+		}
+	}
+	return slogdriver.Trace{}, false
+}
+
+// ParseTraceparent parses a W3C Trace Context traceparent header value.
+//
+// See https://www.w3.org/TR/trace-context/#traceparent-header
+func ParseTraceparent(v string) (slogdriver.Trace, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return slogdriver.Trace{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return slogdriver.Trace{}, false
+	}
+	if !isHex(traceID, 32) || isZero(traceID) || !isHex(spanID, 16) || isZero(spanID) || !isHex(flags, 2) {
+		return slogdriver.Trace{}, false
+	}
+	flagBits, _ := strconv.ParseUint(flags, 16, 8)
+	return slogdriver.Trace{
+		ID:      traceID,
+		SpanID:  spanID,
+		Sampled: flagBits&1 == 1,
+	}, true
+}
+
+// ParseCloudTraceContext parses an X-Cloud-Trace-Context header value of the
+// form TRACE_ID/SPAN_ID;o=OPTIONS, where the span ID and the options are
+// optional. The decimal span ID is converted to the hexadecimal form used by
+// Cloud Logging.
+//
+// See https://cloud.google.com/trace/docs/trace-context#legacy-http-header
+func ParseCloudTraceContext(v string) (slogdriver.Trace, bool) {
+	v, options, _ := strings.Cut(strings.TrimSpace(v), ";")
+	traceID, spanID, hasSpanID := strings.Cut(v, "/")
+	if !isHex(traceID, 32) || isZero(traceID) {
+		return slogdriver.Trace{}, false
+	}
+	trace := slogdriver.Trace{ID: strings.ToLower(traceID)}
+	if hasSpanID {
+		id, err := strconv.ParseUint(spanID, 10, 64)
+		if err != nil {
+			return slogdriver.Trace{}, false
+		}
+		if id != 0 {
+			trace.SpanID = fmt.Sprintf("%016x", id)
+		}
+	}
+	trace.Sampled = options == "o=1"
+	return trace, true
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+const (
+	metadataTraceparent       = "traceparent"
+	metadataCloudTraceContext = "x-cloud-trace-context"
+)
@@ -0,0 +1,252 @@
+package propagation_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/propagation"
+)
+
+func TestTraceFromMetadata(t *testing.T) {
+	const (
+		traceparent       = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		cloudTraceContext = "105445aa7843bc8bf206b12000100000/1;o=0"
+	)
+
+	tests := []struct {
+		name       string
+		md         map[string][]string
+		expected   slogdriver.Trace
+		expectedOK bool
+	}{
+		{
+			"traceparent",
+			map[string][]string{"traceparent": {traceparent}},
+			slogdriver.Trace{
+				ID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			true,
+		},
+		{
+			"x-cloud-trace-context",
+			map[string][]string{"x-cloud-trace-context": {cloudTraceContext}},
+			slogdriver.Trace{
+				ID:     "105445aa7843bc8bf206b12000100000",
+				SpanID: "0000000000000001",
+			},
+			true,
+		},
+		{
+			"both prefers traceparent",
+			map[string][]string{
+				"traceparent":           {traceparent},
+				"x-cloud-trace-context": {cloudTraceContext},
+			},
+			slogdriver.Trace{
+				ID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			true,
+		},
+		{
+			"invalid traceparent falls back",
+			map[string][]string{
+				"traceparent":           {"garbage"},
+				"x-cloud-trace-context": {cloudTraceContext},
+			},
+			slogdriver.Trace{
+				ID:     "105445aa7843bc8bf206b12000100000",
+				SpanID: "0000000000000001",
+			},
+			true,
+		},
+		{
+			"neither",
+			map[string][]string{"other": {"value"}},
+			slogdriver.Trace{},
+			false,
+		},
+		{
+			"nil",
+			nil,
+			slogdriver.Trace{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received, ok := propagation.TraceFromMetadata(tt.md)
+
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
+
+func TestTraceFromRequest(t *testing.T) {
+	const (
+		traceparent       = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		cloudTraceContext = "105445aa7843bc8bf206b12000100000/1;o=0"
+	)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		expected   slogdriver.Trace
+		expectedOK bool
+	}{
+		{
+			"traceparent",
+			map[string]string{"traceparent": traceparent},
+			slogdriver.Trace{
+				ID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			true,
+		},
+		{
+			"X-Cloud-Trace-Context",
+			map[string]string{"X-Cloud-Trace-Context": cloudTraceContext},
+			slogdriver.Trace{
+				ID:     "105445aa7843bc8bf206b12000100000",
+				SpanID: "0000000000000001",
+			},
+			true,
+		},
+		{
+			"both prefers traceparent",
+			map[string]string{
+				"traceparent":           traceparent,
+				"X-Cloud-Trace-Context": cloudTraceContext,
+			},
+			slogdriver.Trace{
+				ID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			true,
+		},
+		{
+			"invalid traceparent falls back",
+			map[string]string{
+				"traceparent":           "garbage",
+				"X-Cloud-Trace-Context": cloudTraceContext,
+			},
+			slogdriver.Trace{
+				ID:     "105445aa7843bc8bf206b12000100000",
+				SpanID: "0000000000000001",
+			},
+			true,
+		},
+		{
+			"neither",
+			map[string]string{"Other": "value"},
+			slogdriver.Trace{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			received, ok := propagation.TraceFromRequest(r)
+
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		expected   slogdriver.Trace
+		expectedOK bool
+	}{
+		{
+			"sampled",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			slogdriver.Trace{ID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+			true,
+		},
+		{
+			"not sampled",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			slogdriver.Trace{ID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+			true,
+		},
+		{
+			"future version with extra fields",
+			"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			slogdriver.Trace{ID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+			true,
+		},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", slogdriver.Trace{}, false},
+		{"extra fields in version 00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", slogdriver.Trace{}, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", slogdriver.Trace{}, false},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", slogdriver.Trace{}, false},
+		{"short trace ID", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", slogdriver.Trace{}, false},
+		{"empty", "", slogdriver.Trace{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received, ok := propagation.ParseTraceparent(tt.value)
+
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
+
+func TestParseCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		expected   slogdriver.Trace
+		expectedOK bool
+	}{
+		{
+			"full",
+			"105445aa7843bc8bf206b12000100000/255;o=1",
+			slogdriver.Trace{ID: "105445aa7843bc8bf206b12000100000", SpanID: "00000000000000ff", Sampled: true},
+			true,
+		},
+		{
+			"no options",
+			"105445aa7843bc8bf206b12000100000/255",
+			slogdriver.Trace{ID: "105445aa7843bc8bf206b12000100000", SpanID: "00000000000000ff"},
+			true,
+		},
+		{
+			"trace ID only",
+			"105445AA7843BC8BF206B12000100000",
+			slogdriver.Trace{ID: "105445aa7843bc8bf206b12000100000"},
+			true,
+		},
+		{"invalid span ID", "105445aa7843bc8bf206b12000100000/abc;o=1", slogdriver.Trace{}, false},
+		{"invalid trace ID", "xyz/1;o=1", slogdriver.Trace{}, false},
+		{"empty", "", slogdriver.Trace{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received, ok := propagation.ParseCloudTraceContext(tt.value)
+
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
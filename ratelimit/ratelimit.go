@@ -0,0 +1,181 @@
+// Package ratelimit provides a slog.Handler that enforces a hard per-severity
+// rate limit on the log entries, so that e.g. a sudden storm of errors can't
+// flood Cloud Logging.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Config is the configuration for the Handler.
+//
+// The limits are the number of entries per second allowed at each severity,
+// with bursts of up to a second worth of entries. A zero limit means that the
+// severity is not limited.
+type Config struct {
+	DebugsPerSecond float64
+	InfosPerSecond  float64
+	WarnsPerSecond  float64
+	ErrorsPerSecond float64
+
+	// SummaryInterval is the minimum interval between the summary entries
+	// reporting the number of dropped entries. The summaries are emitted when
+	// the next entry after the interval is handled. Defaults to one minute.
+	SummaryInterval time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Handler is a handler that drops the records exceeding the rate limits
+// before passing the rest to the inner handler.
+//
+// The number of dropped records is periodically reported in a warning entry
+// with a "rate_limited_dropped" attr. The limits are shared between the
+// handlers derived with WithAttrs and WithGroup.
+type Handler struct {
+	inner   slog.Handler
+	limiter *limiter
+}
+
+// NewHandler returns a new Handler.
+func NewHandler(inner slog.Handler, config Config) *Handler {
+	if config.SummaryInterval == 0 {
+		config.SummaryInterval = time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Handler{
+		inner: inner,
+		limiter: &limiter{
+			root:            inner,
+			now:             config.Now,
+			summaryInterval: config.SummaryInterval,
+			buckets: [...]bucket{
+				{rate: config.DebugsPerSecond},
+				{rate: config.InfosPerSecond},
+				{rate: config.WarnsPerSecond},
+				{rate: config.ErrorsPerSecond},
+			},
+		},
+	}
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	now := h.limiter.now()
+	allowed, dropped := h.limiter.take(now, r.Level)
+	var err error
+	if dropped != 0 {
+		summary := slog.NewRecord(now, slog.LevelWarn, "rate limited", 0)
+		summary.AddAttrs(slog.Uint64(keyDropped, dropped))
+		err = h.limiter.root.Handle(ctx, summary)
+	}
+	if !allowed {
+		return err
+	}
+	return errors.Join(err, h.inner.Handle(ctx, r))
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(as)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// Unwrap returns the inner handler.
+func (h *Handler) Unwrap() slog.Handler {
+	return h.inner
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+const keyDropped = "rate_limited_dropped"
+
+type limiter struct {
+	root            slog.Handler
+	now             func() time.Time
+	summaryInterval time.Duration
+
+	m           sync.Mutex
+	buckets     [4]bucket
+	dropped     uint64
+	lastSummary time.Time
+}
+
+// take consumes a token for the level, returning whether the record is
+// allowed, and the number of dropped records to report, if it's time for a
+// summary.
+func (l *limiter) take(now time.Time, level slog.Level) (allowed bool, dropped uint64) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.lastSummary.IsZero() {
+		l.lastSummary = now
+	}
+	allowed = l.buckets[bucketIndex(level)].take(now)
+	if !allowed {
+		l.dropped++
+	}
+	if l.dropped != 0 && now.Sub(l.lastSummary) >= l.summaryInterval {
+		dropped = l.dropped
+		l.dropped = 0
+		l.lastSummary = now
+	}
+	return allowed, dropped
+}
+
+func bucketIndex(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return 0
+	case level < slog.LevelWarn:
+		return 1
+	case level < slog.LevelError:
+		return 2
+	}
+	return 3
+}
+
+// bucket is a token bucket with a capacity of a second worth of tokens.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) take(now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	capacity := max(b.rate, 1)
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if now.After(b.last) {
+		b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,176 @@
+package ratelimit_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+	"github.com/jussi-kalliokoski/slogdriver/ratelimit"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("burst past the limit", func(t *testing.T) {
+		ctx := context.Background()
+		var clock fakeClock
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(ratelimit.NewHandler(newHandler(&capture), ratelimit.Config{
+			ErrorsPerSecond: 3,
+			Now:             clock.Now,
+		}))
+
+		for i := 0; i < 10; i++ {
+			logger.ErrorContext(ctx, "error")
+			logger.InfoContext(ctx, "info")
+		}
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, 13, len(entries))
+		require.Equal(t, 3, countMessage(entries, "error"))
+		require.Equal(t, 10, countMessage(entries, "info"))
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		ctx := context.Background()
+		var clock fakeClock
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(ratelimit.NewHandler(newHandler(&capture), ratelimit.Config{
+			WarnsPerSecond: 2,
+			Now:            clock.Now,
+		}))
+
+		for i := 0; i < 5; i++ {
+			logger.WarnContext(ctx, "warn")
+		}
+		clock.Advance(500 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			logger.WarnContext(ctx, "warn")
+		}
+		clock.Advance(10 * time.Second)
+		for i := 0; i < 5; i++ {
+			logger.WarnContext(ctx, "warn")
+		}
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, 5, countMessage(entries, "warn"))
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		ctx := context.Background()
+		var clock fakeClock
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(ratelimit.NewHandler(newHandler(&capture), ratelimit.Config{
+			ErrorsPerSecond: 1,
+			SummaryInterval: 10 * time.Second,
+			Now:             clock.Now,
+		}))
+		logger = logger.WithGroup("Group").With("Foo", "bar")
+		dropped := uint64(4)
+		expected := []Entry{
+			{Message: "error", Severity: "ERROR"},
+			{Message: "rate limited", Severity: "WARNING", Dropped: &dropped},
+			{Message: "error", Severity: "ERROR"},
+			{Message: "error", Severity: "ERROR"},
+		}
+
+		for i := 0; i < 5; i++ {
+			logger.ErrorContext(ctx, "error")
+		}
+		clock.Advance(10 * time.Second)
+		logger.ErrorContext(ctx, "error")
+		clock.Advance(10 * time.Second)
+		logger.ErrorContext(ctx, "error")
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, len(expected), len(entries))
+		for i := range expected {
+			require.Equal(t, expected[i].Message, entries[i].Message)
+			require.Equal(t, expected[i].Severity, entries[i].Severity)
+			require.Equal(t, expected[i].Dropped == nil, entries[i].Dropped == nil)
+			if expected[i].Dropped != nil {
+				require.Equal(t, *expected[i].Dropped, *entries[i].Dropped)
+			}
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		ctx := context.Background()
+		var clock fakeClock
+		var capture slogtest.Capture[Entry]
+		logger, errs := slogtest.NewWithErrorHandler(ratelimit.NewHandler(newHandler(&capture), ratelimit.Config{
+			InfosPerSecond: 50,
+			Now:            clock.Now,
+		}))
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					logger.InfoContext(ctx, "info")
+				}
+			}()
+		}
+		wg.Wait()
+		entries := capture.Entries()
+		err := errs.Err()
+
+		require.NoError(t, err)
+		require.Equal(t, 50, len(entries))
+	})
+}
+
+type Entry struct {
+	Message  string  `json:"message"`
+	Severity string  `json:"severity"`
+	Dropped  *uint64 `json:"rate_limited_dropped"`
+}
+
+func newHandler(capture *slogtest.Capture[Entry]) slog.Handler {
+	return slogdriver.NewHandler(capture, slogdriver.Config{
+		Level:          slog.LevelDebug,
+		SeverityFormat: slogdriver.SeverityFormatString,
+	})
+}
+
+func countMessage(entries []Entry, message string) int {
+	n := 0
+	for _, entry := range entries {
+		if entry.Message == message {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeClock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.now.IsZero() {
+		c.now = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	now := c.Now()
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = now.Add(d)
+}
@@ -0,0 +1,17 @@
+package slogdriver
+
+import "context"
+
+// WithRequestID returns a new Context with a request ID to be written to the
+// log entries produced using that context, in the field defined by
+// Config.RequestIDKey.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKeyT{}, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDContextKeyT{}).(string)
+	return v
+}
+
+type requestIDContextKeyT struct{}
@@ -0,0 +1,26 @@
+package slogdriver
+
+import "context"
+
+// MonitoredResource identifies the resource that produced a log entry, e.g. a
+// Pub/Sub topic.
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/MonitoredResource
+type MonitoredResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// WithResource returns a new Context with a MonitoredResource to be used in the
+// log entries produced using that context instead of Config.Resource. A
+// MonitoredResource with an empty Type falls back to Config.Resource.
+func WithResource(ctx context.Context, resource MonitoredResource) context.Context {
+	return context.WithValue(ctx, resourceContextKeyT{}, resource)
+}
+
+func resourceFromContext(ctx context.Context) MonitoredResource {
+	v, _ := ctx.Value(resourceContextKeyT{}).(MonitoredResource)
+	return v
+}
+
+type resourceContextKeyT struct{}
@@ -0,0 +1,70 @@
+package slogdriver
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+)
+
+// SamplerConfig is the configuration for the Sampler.
+type SamplerConfig struct {
+	// Rate is the fraction of the records to keep, between 0 and 1. The
+	// records logged within a sampled Trace or with ForceSample are always
+	// kept.
+	Rate float64
+
+	// Random returns a pseudo-random number in [0, 1). Defaults to
+	// rand.Float64.
+	Random func() float64
+}
+
+// Sampler is a handler that passes a random sample of the records to the
+// inner handler, thinning out the volume of the logs.
+//
+// The records logged with a Context that has a sampled Trace, or that is
+// returned by ForceSample, are always passed, so that the traces that are
+// sampled in have their logs intact.
+type Sampler struct {
+	inner  slog.Handler
+	config SamplerConfig
+}
+
+// NewSampler returns a new Sampler.
+func NewSampler(inner slog.Handler, config SamplerConfig) *Sampler {
+	if config.Random == nil {
+		config.Random = rand.Float64
+	}
+	return &Sampler{inner: inner, config: config}
+}
+
+// Handle implements slog.Handler.
+func (h *Sampler) Handle(ctx context.Context, r slog.Record) error {
+	if !traceFromContext(ctx).Sampled && !isForceSampled(ctx) && h.config.Random() >= h.config.Rate {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Sampler) WithAttrs(as []slog.Attr) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithAttrs(as)
+	return &clone
+}
+
+// WithGroup implements slog.Handler.
+func (h *Sampler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// Unwrap returns the inner handler.
+func (h *Sampler) Unwrap() slog.Handler {
+	return h.inner
+}
+
+// Enabled implements slog.Handler.
+func (h *Sampler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
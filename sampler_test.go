@@ -0,0 +1,89 @@
+package slogdriver_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+func TestSampler(t *testing.T) {
+	type Entry struct {
+		Message string `json:"message"`
+		Foo     string `json:"foo"`
+	}
+
+	tests := []struct {
+		name        string
+		trace       *slogdriver.Trace
+		forceSample bool
+		expected    []Entry
+	}{
+		{
+			"no trace",
+			nil,
+			false,
+			[]Entry{{"1", "bar"}, {"3", "bar"}},
+		},
+		{
+			"unsampled trace",
+			&slogdriver.Trace{ID: "abc", Sampled: false},
+			false,
+			[]Entry{{"1", "bar"}, {"3", "bar"}},
+		},
+		{
+			"sampled trace",
+			&slogdriver.Trace{ID: "abc", Sampled: true},
+			false,
+			[]Entry{{"0", "bar"}, {"1", "bar"}, {"2", "bar"}, {"3", "bar"}},
+		},
+		{
+			"forced sampling",
+			&slogdriver.Trace{ID: "abc", Sampled: false},
+			true,
+			[]Entry{{"0", "bar"}, {"1", "bar"}, {"2", "bar"}, {"3", "bar"}},
+		},
+		{
+			"forced sampling without trace",
+			nil,
+			true,
+			[]Entry{{"0", "bar"}, {"1", "bar"}, {"2", "bar"}, {"3", "bar"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.trace != nil {
+				ctx = tt.trace.Context(ctx)
+			}
+			if tt.forceSample {
+				ctx = slogdriver.ForceSample(ctx)
+			}
+			var capture slogtest.Capture[Entry]
+			random := []float64{0.5, 0.25, 0.75, 0}
+			h := slogdriver.NewSampler(slogdriver.NewHandler(&capture, slogdriver.Config{}), slogdriver.SamplerConfig{
+				Rate: 0.5,
+				Random: func() float64 {
+					v := random[0]
+					random = random[1:]
+					return v
+				},
+			})
+			logger, errs := slogtest.NewWithErrorHandler(h)
+			logger = logger.With(slog.String("foo", "bar"))
+
+			for _, msg := range []string{"0", "1", "2", "3"} {
+				logger.InfoContext(ctx, msg)
+			}
+			received := capture.Entries()
+			err := errs.Err()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, received)
+		})
+	}
+}
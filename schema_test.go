@@ -0,0 +1,181 @@
+package slogdriver_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+	"github.com/jussi-kalliokoski/slogdriver/internal/slogtest"
+)
+
+// TestGCPSchema validates the entries against the special fields documented
+// in https://cloud.google.com/logging/docs/structured-logging, using literal
+// keys rather than the Field constants, so that a renamed key fails loudly.
+func TestGCPSchema(t *testing.T) {
+	entrySchema := func(severity schema) schema {
+		return schema{
+			kind:   kindObject,
+			prefix: "logging.googleapis.com/",
+			fields: map[string]schema{
+				"message":   {kind: kindString, required: true},
+				"timestamp": {kind: kindTimestamp, required: true},
+				"severity":  severity,
+				"logging.googleapis.com/sourceLocation": {
+					kind:     kindObject,
+					required: true,
+					fields: map[string]schema{
+						"file":     {kind: kindString, required: true},
+						"line":     {kind: kindNumber, required: true},
+						"function": {kind: kindString, required: true},
+					},
+				},
+				"logging.googleapis.com/trace":         {kind: kindString, required: true, pattern: "projects/jectpro/traces/"},
+				"logging.googleapis.com/spanId":        {kind: kindString, required: true},
+				"logging.googleapis.com/trace_sampled": {kind: kindBool, required: true},
+				"logging.googleapis.com/labels":        {kind: kindStringMap, required: true},
+				"logging.googleapis.com/insertId":      {kind: kindString, required: true},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format slogdriver.SeverityFormat
+		schema schema
+	}{
+		{
+			"numeric severity",
+			slogdriver.SeverityFormatNumeric,
+			entrySchema(schema{kind: kindNumber, required: true, enum: []any{float64(0), float64(100), float64(200), float64(300), float64(400), float64(500), float64(600), float64(700), float64(800)}}),
+		},
+		{
+			"string severity",
+			slogdriver.SeverityFormatString,
+			entrySchema(schema{kind: kindString, required: true, enum: []any{"DEFAULT", "DEBUG", "INFO", "NOTICE", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ctx = slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true}.Context(ctx)
+			ctx = slogdriver.AddLabels(ctx, slogdriver.NewLabel("foo", "bar"))
+			var capture slogtest.Capture[map[string]any]
+			logger, errs := slogtest.NewWithErrorHandler(slogdriver.NewHandler(&capture, slogdriver.Config{
+				ProjectID:        "jectpro",
+				SeverityFormat:   tt.format,
+				GenerateInsertID: true,
+			}))
+			var pcs [1]uintptr
+			runtime.Callers(1, pcs[:])
+			r := slog.NewRecord(time.Now(), slog.LevelWarn, "representative", pcs[0])
+			r.AddAttrs(slog.String("payload", "value"), slog.Group("group", slog.Int("n", 1)))
+
+			err := logger.Handler().Handle(ctx, r)
+			var received map[string]any
+			decodeErr := json.Unmarshal(capture.Raw(), &received)
+
+			require.NoError(t, err)
+			require.NoError(t, errs.Err())
+			require.NoError(t, decodeErr)
+			require.Equal(t, []string(nil), tt.schema.validate("", received))
+		})
+	}
+}
+
+type schemaKind int
+
+const (
+	kindString schemaKind = iota
+	kindNumber
+	kindBool
+	kindTimestamp
+	kindStringMap
+	kindObject
+)
+
+// schema is a minimal JSON schema for validating the entries.
+type schema struct {
+	kind     schemaKind
+	required bool
+	enum     []any
+	pattern  string            // required prefix of a string
+	fields   map[string]schema // the fields of an object
+	prefix   string            // the keys with the prefix must be in fields
+}
+
+func (s schema) validate(path string, v any) []string {
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.kind {
+	case kindString, kindTimestamp:
+		str, ok := v.(string)
+		if !ok {
+			fail("expected a string, got %T", v)
+			return problems
+		}
+		if !strings.HasPrefix(str, s.pattern) {
+			fail("expected prefix %q, got %q", s.pattern, str)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, str); s.kind == kindTimestamp && err != nil {
+			fail("expected an RFC 3339 timestamp, got %q", str)
+		}
+	case kindNumber:
+		if _, ok := v.(float64); !ok {
+			fail("expected a number, got %T", v)
+		}
+	case kindBool:
+		if _, ok := v.(bool); !ok {
+			fail("expected a bool, got %T", v)
+		}
+	case kindStringMap:
+		m, ok := v.(map[string]any)
+		if !ok {
+			fail("expected an object, got %T", v)
+			return problems
+		}
+		for key, value := range m {
+			if _, ok := value.(string); !ok {
+				fail("expected a string value for %q, got %T", key, value)
+			}
+		}
+	case kindObject:
+		m, ok := v.(map[string]any)
+		if !ok {
+			fail("expected an object, got %T", v)
+			return problems
+		}
+		for key, field := range s.fields {
+			value, ok := m[key]
+			if !ok {
+				if field.required {
+					fail("missing %q", key)
+				}
+				continue
+			}
+			problems = append(problems, field.validate(path+"/"+key, value)...)
+		}
+		for key := range m {
+			if _, ok := s.fields[key]; !ok && s.prefix != "" && strings.HasPrefix(key, s.prefix) {
+				fail("unknown special field %q", key)
+			}
+		}
+	}
+
+	if s.enum != nil && !slices.Contains(s.enum, v) {
+		fail("expected one of %v, got %v", s.enum, v)
+	}
+
+	return problems
+}
@@ -0,0 +1,225 @@
+package slogdriver
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// SeverityFormat defines how the severity of the entries is encoded.
+type SeverityFormat int
+
+const (
+	// SeverityFormatNumeric encodes the severity as a number, e.g. 400.
+	SeverityFormatNumeric SeverityFormat = iota
+	// SeverityFormatString encodes the severity as a LogSeverity name, e.g.
+	// "WARNING".
+	//
+	// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+	SeverityFormatString
+	// SeverityFormatEnum encodes the severity as its LogSeverity enum value,
+	// e.g. 200 for INFO and 300 for NOTICE. Unlike with
+	// SeverityFormatNumeric, which maps DEBUG to 200 and both INFO and NOTICE
+	// to 300, every severity has a distinct number that matches the
+	// LogSeverity enum of the Cloud Logging API.
+	SeverityFormatEnum
+)
+
+// LevelCritical is a level above slog.LevelError that is mapped to the
+// CRITICAL severity.
+const LevelCritical = slog.LevelError + 4
+
+// levelName returns the name of the level, as returned by slog.Level.String,
+// except for LevelCritical, which is named "CRITICAL".
+func levelName(level slog.Level) string {
+	if level == LevelCritical {
+		return severityCritical.Name()
+	}
+	return level.String()
+}
+
+// LevelFunc is an adapter to allow the use of ordinary functions as a
+// slog.Leveler, e.g. for reading the minimum level from a feature flag
+// service. The function is called on every Level call.
+type LevelFunc func() slog.Level
+
+// Level implements slog.Leveler.
+func (fn LevelFunc) Level() slog.Level {
+	return fn()
+}
+
+type severity int
+
+const (
+	severityDebug severity = iota
+	severityInfo
+	severityNotice
+	severityWarning
+	severityError
+	severityCritical
+	severityAlert
+	severityEmergency
+	severityDefault
+)
+
+// SeverityAttrKey is the key of the string attrs of records that override the
+// severity of the entry with a LogSeverity name, e.g.
+// slog.String(SeverityAttrKey, "ALERT"), for passing through the severities
+// of other logging systems. Only the top-level attrs of the records, outside
+// the groups opened with WithGroup, override the severity, and they're left
+// out of the payload. The attrs with other values are written as is, and
+// reported as errors with Config.StrictSeverity.
+const SeverityAttrKey = "severity"
+
+// parseSeverity returns the severity with the LogSeverity name.
+func parseSeverity(name string) (severity, bool) {
+	for s := severityDebug; s <= severityDefault; s++ {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// recordSeverity returns the severity of the record, overridden by the last
+// attr with SeverityAttrKey, if any. The overrides are clamped the same way as
+// the levels.
+func (h *Handler) recordSeverity(r *slog.Record) (severity, error) {
+	s := h.severityOf(r.Level)
+	var err error
+	if h.inGroup || r.NumAttrs() == 0 {
+		return s, nil
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != SeverityAttrKey || a.Value.Kind() != slog.KindString {
+			return true
+		}
+		if parsed, ok := parseSeverity(a.Value.String()); ok {
+			s, err = h.clampSeverity(parsed), nil
+		} else if h.config.StrictSeverity {
+			err = fmt.Errorf("slogdriver: unknown severity %q", a.Value.String())
+		}
+		return true
+	})
+	return s, err
+}
+
+// clampSeverity clamps the severity between the severities of
+// Config.MinSeverity and Config.MaxSeverity, comparing their LogSeverity enum
+// values, so that DEFAULT is the lowest.
+func (h *Handler) clampSeverity(s severity) severity {
+	if h.config.MinSeverity != nil {
+		if lower := h.severityOf(h.config.MinSeverity.Level()); s.Enum() < lower.Enum() {
+			s = lower
+		}
+	}
+	if h.config.MaxSeverity != nil {
+		if upper := h.severityOf(h.config.MaxSeverity.Level()); s.Enum() > upper.Enum() {
+			s = upper
+		}
+	}
+	return s
+}
+
+// isSeverityAttr reports whether the attr overrides the severity of the entry
+// instead of being written to the payload.
+func (h *Handler) isSeverityAttr(a slog.Attr) bool {
+	if h.inGroup || a.Key != SeverityAttrKey || a.Value.Kind() != slog.KindString {
+		return false
+	}
+	_, ok := parseSeverity(a.Value.String())
+	return ok
+}
+
+func (h *Handler) severityOf(level slog.Level) severity {
+	if h.config.MinSeverity != nil {
+		level = max(level, h.config.MinSeverity.Level())
+	}
+	if h.config.MaxSeverity != nil {
+		level = min(level, h.config.MaxSeverity.Level())
+	}
+	switch {
+	case level >= LevelCritical:
+		return severityCritical
+	case level >= slog.LevelError:
+		return severityError
+	case level >= slog.LevelWarn:
+		return severityWarning
+	case h.config.NoticeLevel != nil && level >= h.config.NoticeLevel.Level():
+		return severityNotice
+	case level >= slog.LevelInfo:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// Number returns the numeric form of the severity. NOTICE shares the number
+// of INFO, use SeverityFormatString or SeverityFormatEnum to tell them apart.
+func (s severity) Number() uint64 {
+	switch s {
+	case severityDefault:
+		return 0
+	case severityEmergency:
+		return 800
+	case severityAlert:
+		return 700
+	case severityCritical:
+		return 600
+	case severityError:
+		return 500
+	case severityWarning:
+		return 400
+	case severityNotice, severityInfo:
+		return 300
+	default:
+		return 200
+	}
+}
+
+// Enum returns the LogSeverity enum value of the severity.
+func (s severity) Enum() uint64 {
+	switch s {
+	case severityDefault:
+		return 0
+	case severityEmergency:
+		return 800
+	case severityAlert:
+		return 700
+	case severityCritical:
+		return 600
+	case severityError:
+		return 500
+	case severityWarning:
+		return 400
+	case severityNotice:
+		return 300
+	case severityInfo:
+		return 200
+	default:
+		return 100
+	}
+}
+
+// Name returns the LogSeverity name of the severity.
+func (s severity) Name() string {
+	switch s {
+	case severityDefault:
+		return "DEFAULT"
+	case severityEmergency:
+		return "EMERGENCY"
+	case severityAlert:
+		return "ALERT"
+	case severityCritical:
+		return "CRITICAL"
+	case severityError:
+		return "ERROR"
+	case severityWarning:
+		return "WARNING"
+	case severityNotice:
+		return "NOTICE"
+	case severityInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
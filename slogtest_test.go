@@ -0,0 +1,50 @@
+package slogdriver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+// TestSlogtest runs the Handler through the handler rules compliance suite of
+// the standard library.
+//
+// The intentional deviations from slog.JSONHandler are mapped back before the
+// checks: the message, timestamp and severity fields are renamed to the ones
+// expected by Cloud Logging, and the severity is numeric.
+func TestSlogtest(t *testing.T) {
+	var buf bytes.Buffer
+	h := slogdriver.NewHandler(&buf, slogdriver.Config{})
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
+			if len(line) == 0 {
+				continue
+			}
+			var m map[string]any
+			require.NoError(t, json.Unmarshal(line, &m))
+			renameKey(m, slogdriver.FieldMessage, slog.MessageKey)
+			renameKey(m, slogdriver.FieldTimestamp, slog.TimeKey)
+			renameKey(m, slogdriver.FieldSeverity, slog.LevelKey)
+			ms = append(ms, m)
+		}
+		return ms
+	}
+
+	err := slogtest.TestHandler(h, results)
+
+	require.NoError(t, err)
+}
+
+func renameKey(m map[string]any, from, to string) {
+	if v, ok := m[from]; ok {
+		delete(m, from)
+		m[to] = v
+	}
+}
@@ -0,0 +1,14 @@
+package slogdriver
+
+// SourceStyle defines how the source location of the entries is written.
+type SourceStyle int
+
+const (
+	// SourceStyleObject writes the source location as a record in the
+	// logging.googleapis.com/sourceLocation field, as expected by the logging
+	// agent.
+	SourceStyleObject SourceStyle = iota
+	// SourceStyleString writes the source location as a compact "file:line"
+	// string in a "caller" field, e.g. "handler.go:123".
+	SourceStyleString
+)
@@ -0,0 +1,109 @@
+package slogdriver
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// stackTrace returns the stack trace written with Config.ReportStackTraces,
+// in the format of the Go panics that Error Reporting recognizes, headed by
+// the message of the error. The stack trace is taken from the first error in
+// the attrs of the record that exposes one, or from the goroutine calling
+// Handle if none do, in which case the message is that of the first error, or
+// of the record if there are no error attrs.
+func (h *Handler) stackTrace(r *slog.Record) string {
+	msg := r.Message
+	var pcs []uintptr
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		err, ok := h.resolve(a.Value).Any().(error)
+		if !ok || isNil(err) {
+			return true
+		}
+		pcs = errorStackTrace(err)
+		if pcs != nil || !found {
+			msg = err.Error()
+		}
+		found = true
+		return pcs == nil
+	})
+
+	var b strings.Builder
+	b.WriteString(msg)
+	b.WriteString("\n\n")
+	if pcs == nil {
+		b.Write(currentStack())
+		return b.String()
+	}
+	b.WriteString("goroutine ")
+	b.WriteString(strconv.FormatUint(goroutineID(), 10))
+	b.WriteString(" [running]:\n")
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" {
+			b.WriteString(f.Function)
+			b.WriteString("()\n\t")
+			b.WriteString(f.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(f.Line))
+			b.WriteByte('\n')
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// errorStackTrace returns the program counters of the deepest error in the
+// chain that has a StackTrace method returning a slice of them, such as the
+// errors of github.com/pkg/errors, or nil if there's no such error.
+func errorStackTrace(err error) []uintptr {
+	var pcs []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		if trace := stackTraceMethod(err); trace != nil {
+			pcs = trace
+		}
+	}
+	return pcs
+}
+
+func stackTraceMethod(err error) []uintptr {
+	if isNil(err) {
+		return nil
+	}
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil
+	}
+	t := m.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice || t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	v := m.Call(nil)[0]
+	pcs := make([]uintptr, v.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(v.Index(i).Uint())
+	}
+	return pcs
+}
+
+// currentStack returns the stack trace of the current goroutine, as formatted
+// by runtime.Stack.
+func currentStack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+const fieldStackTrace = "stack_trace"
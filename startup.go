@@ -0,0 +1,40 @@
+package slogdriver
+
+import (
+	"os"
+	"time"
+)
+
+// processStart approximates the start time of the process with the time the
+// package was initialized.
+var processStart = time.Now()
+
+// writeStartupRecord writes the record added to the beginning of the stream
+// by Config.EmitStartupRecord, containing the start time, the PID and the
+// version of the process under a "startup" field. The version is taken from
+// Config.ServiceContext, or the VCS revision the binary was built from.
+func (h *Handler) writeStartupRecord() error {
+	l := h.newLine()
+	l.AddString(h.config.MessageKey, startupMessage)
+	l.StartRecord(fieldStartup)
+	l.AddTime(fieldStartupTime, processStart.Round(0))
+	l.AddInt64(fieldStartupPID, int64(os.Getpid()))
+	version := h.config.ServiceContext.Version
+	if version == "" {
+		version = buildRevision()
+	}
+	if version != "" {
+		l.AddString(fieldStartupVersion, version)
+	}
+	l.EndRecord()
+	return l.End()
+}
+
+const startupMessage = "startup"
+
+const (
+	fieldStartup        = "startup"
+	fieldStartupTime    = "startTime"
+	fieldStartupPID     = "pid"
+	fieldStartupVersion = "version"
+)
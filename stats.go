@@ -0,0 +1,59 @@
+package slogdriver
+
+import "sync/atomic"
+
+// Stats contains the counts of entries written per severity, and the count of
+// entries dropped due to errors from the underlying writer. The ALERT and
+// EMERGENCY entries are counted as Critical, and the DEFAULT entries as Debug.
+type Stats struct {
+	Debug       uint64
+	Info        uint64
+	Notice      uint64
+	Warn        uint64
+	Error       uint64
+	Critical    uint64
+	WriteErrors uint64
+}
+
+type stats struct {
+	debug       atomic.Uint64
+	info        atomic.Uint64
+	notice      atomic.Uint64
+	warn        atomic.Uint64
+	error       atomic.Uint64
+	critical    atomic.Uint64
+	writeErrors atomic.Uint64
+}
+
+func (s *stats) addEntry(severity severity) {
+	switch severity {
+	case severityCritical, severityAlert, severityEmergency:
+		s.critical.Add(1)
+	case severityError:
+		s.error.Add(1)
+	case severityWarning:
+		s.warn.Add(1)
+	case severityNotice:
+		s.notice.Add(1)
+	case severityInfo:
+		s.info.Add(1)
+	default:
+		s.debug.Add(1)
+	}
+}
+
+func (s *stats) addWriteError() {
+	s.writeErrors.Add(1)
+}
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Debug:       s.debug.Load(),
+		Info:        s.info.Load(),
+		Notice:      s.notice.Load(),
+		Warn:        s.warn.Load(),
+		Error:       s.error.Load(),
+		Critical:    s.critical.Load(),
+		WriteErrors: s.writeErrors.Load(),
+	}
+}
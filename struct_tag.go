@@ -0,0 +1,210 @@
+package slogdriver
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// addTagged writes the value like encoding/json would, except that the keys
+// of the struct fields are read from Config.StructTag instead of the json tag.
+// The pointers, maps and slices being written are tracked in visiting, and the
+// ones that contain themselves are written as null with an error, where
+// encoding/json would return an error for the whole value.
+func (h *Handler) addTagged(l LineWriter, key string, v reflect.Value, visiting map[taggedRef]struct{}) error {
+	if !v.IsValid() {
+		return l.AddMarshal(key, nil)
+	}
+	if v.CanInterface() {
+		switch v.Interface().(type) {
+		case json.Marshaler, encoding.TextMarshaler:
+			return addMarshal(l, key, v.Interface())
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		l.AddString(key, v.String())
+		return nil
+	case reflect.Bool:
+		l.AddBool(key, v.Bool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		l.AddInt64(key, v.Int())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		l.AddUint64(key, v.Uint())
+		return nil
+	case reflect.Float64:
+		l.AddFloat64(key, v.Float())
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return l.AddMarshal(key, nil)
+		}
+		if v.Kind() == reflect.Pointer {
+			ref, ok := visit(visiting, v)
+			if !ok {
+				return addCycle(l, key, v)
+			}
+			defer delete(visiting, ref)
+		}
+		return h.addTagged(l, key, v.Elem(), visiting)
+	case reflect.Struct:
+		l.StartRecord(key)
+		defer l.EndRecord()
+		return h.addTaggedFields(l, v, visiting)
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		ref, ok := visit(visiting, v)
+		if !ok {
+			return addCycle(l, key, v)
+		}
+		defer delete(visiting, ref)
+		l.StartRecord(key)
+		defer l.EndRecord()
+		keys := v.MapKeys()
+		names := make(map[string]reflect.Value, len(keys))
+		for _, k := range keys {
+			names[k.String()] = k
+		}
+		var err error
+		for _, name := range sortedKeys(names) {
+			err = errors.Join(err, h.addTagged(l, name, v.MapIndex(names[name]), visiting))
+		}
+		return err
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			break
+		}
+		if v.Kind() == reflect.Slice {
+			ref, ok := visit(visiting, v)
+			if !ok {
+				return addCycle(l, key, v)
+			}
+			defer delete(visiting, ref)
+		}
+		l.StartList(key)
+		defer l.EndList()
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			err = errors.Join(err, h.addTagged(l, "", v.Index(i), visiting))
+		}
+		return err
+	}
+
+	if !v.CanInterface() {
+		return l.AddMarshal(key, nil)
+	}
+	return addMarshal(l, key, v.Interface())
+}
+
+func (h *Handler) addTaggedFields(l LineWriter, v reflect.Value, visiting map[taggedRef]struct{}) error {
+	var err error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitEmpty := parseStructTag(f.Tag.Get(h.config.StructTag))
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			var ref taggedRef
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				var ok bool
+				if ref, ok = visit(visiting, fv); !ok {
+					err = errors.Join(err, fmt.Errorf("slogdriver: cycle detected in value of type %s", fv.Type()))
+					continue
+				}
+				ft = ft.Elem()
+				fv = fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				err = errors.Join(err, h.addTaggedFields(l, fv, visiting))
+				delete(visiting, ref)
+				continue
+			}
+			delete(visiting, ref)
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		err = errors.Join(err, h.addTagged(l, name, fv, visiting))
+	}
+	return err
+}
+
+// taggedRef identifies a pointer, map or slice written by addTagged. The
+// length tells apart the slices of the same array, and the type tells apart
+// a pointer to a struct and a pointer to its first field.
+type taggedRef struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// visit adds the pointer, map or slice to visiting, returning false if it's
+// already there, i.e. if the value contains itself.
+func visit(visiting map[taggedRef]struct{}, v reflect.Value) (taggedRef, bool) {
+	ref := taggedRef{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+	if _, ok := visiting[ref]; ok {
+		return ref, false
+	}
+	visiting[ref] = struct{}{}
+	return ref, true
+}
+
+// addCycle writes null in place of a value that contains itself.
+func addCycle(l LineWriter, key string, v reflect.Value) error {
+	return errors.Join(
+		fmt.Errorf("slogdriver: cycle detected in value of type %s", v.Type()),
+		l.AddMarshal(key, nil),
+	)
+}
+
+func parseStructTag(tag string) (name string, omitEmpty bool) {
+	name, opts, _ := strings.Cut(tag, ",")
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// isEmptyValue matches the definition of empty values of the omitempty
+// option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
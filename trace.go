@@ -1,19 +1,67 @@
 package slogdriver
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 // Trace contains tracing information used in logging.
+//
+// The ID is either a bare trace ID, which is prefixed with the resource name
+// of the trace in Config.ProjectID, or a full resource name of the form
+// "projects/<project>/traces/<id>", which is used as is.
 type Trace struct {
 	ID      string
 	SpanID  string
 	Sampled bool
 }
 
+// WithTrace returns a Context that stores a Trace with the given information.
+// It is a shorthand for Trace.Context.
+func WithTrace(ctx context.Context, id, spanID string, sampled bool) context.Context {
+	return Trace{ID: id, SpanID: spanID, Sampled: sampled}.Context(ctx)
+}
+
+// TraceFromContext returns the Trace stored in the Context, if any.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	v, ok := ctx.Value(traceContextKeyT{}).(Trace)
+	return v, ok
+}
+
 func traceFromContext(ctx context.Context) Trace {
-	v, _ := ctx.Value(traceContextKeyT{}).(Trace)
+	v, _ := TraceFromContext(ctx)
 	return v
 }
 
+// TraceAttr returns an attr that sets the Trace of the entries, as an
+// alternative to Trace.Context for attaching the trace to a logger, e.g.
+// logger.With(slogdriver.TraceAttr(trace)).
+//
+// Attrs with Trace values are written to the trace fields instead of the
+// payload, taking precedence over the Trace in the Context. The Trace of an
+// attr of the record takes precedence over the Trace of an attr added with
+// WithAttrs.
+func TraceAttr(trace Trace) slog.Attr {
+	return slog.Any(keyTrace, trace)
+}
+
+const keyTrace = "trace"
+
+// ForceSample returns a Context whose entries are logged as if the Trace was
+// sampled, e.g. for debugging a specific request in full even though it
+// wasn't sampled upstream. The trace_sampled field of the entries is written
+// as true and Sampler passes all the records.
+func ForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleContextKeyT{}, true)
+}
+
+func isForceSampled(ctx context.Context) bool {
+	v, _ := ctx.Value(forceSampleContextKeyT{}).(bool)
+	return v
+}
+
+type forceSampleContextKeyT struct{}
+
 // Context returns a Context that stores the Trace.
 func (trace Trace) Context(ctx context.Context) context.Context {
 	return context.WithValue(ctx, traceContextKeyT{}, trace)
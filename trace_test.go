@@ -0,0 +1,51 @@
+package slogdriver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jussi-kalliokoski/slogdriver"
+	"github.com/jussi-kalliokoski/slogdriver/internal/require"
+)
+
+func TestTraceFromContext(t *testing.T) {
+	t.Run("no trace", func(t *testing.T) {
+		received, ok := slogdriver.TraceFromContext(context.Background())
+
+		require.Equal(t, false, ok)
+		require.Equal(t, slogdriver.Trace{}, received)
+	})
+
+	t.Run("Trace.Context", func(t *testing.T) {
+		expected := slogdriver.Trace{ID: "abc", SpanID: "def", Sampled: true}
+		ctx := expected.Context(context.Background())
+
+		received, ok := slogdriver.TraceFromContext(ctx)
+
+		require.Equal(t, true, ok)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("WithTrace", func(t *testing.T) {
+		expected := slogdriver.Trace{ID: "bcd", SpanID: "efg", Sampled: true}
+		ctx := slogdriver.WithTrace(context.Background(), "bcd", "efg", true)
+
+		received, ok := slogdriver.TraceFromContext(ctx)
+
+		require.Equal(t, true, ok)
+		require.Equal(t, expected, received)
+	})
+
+	t.Run("derived context", func(t *testing.T) {
+		type key struct{}
+		expected := slogdriver.Trace{ID: "cde"}
+		ctx := slogdriver.WithTrace(context.Background(), "cde", "", false)
+		ctx, cancel := context.WithCancel(context.WithValue(ctx, key{}, "value"))
+		defer cancel()
+
+		received, ok := slogdriver.TraceFromContext(ctx)
+
+		require.Equal(t, true, ok)
+		require.Equal(t, expected, received)
+	})
+}
@@ -0,0 +1,53 @@
+package slogdriver
+
+import (
+	"bytes"
+	"log/slog"
+
+	"github.com/jussi-kalliokoski/goldjson/tokens"
+)
+
+// TypedPayload returns an attr value that marshals v with an "@type" field of
+// the typeURL merged into it, in the manner of protobuf Any, e.g.
+// slog.Any("payload", TypedPayload("type.googleapis.com/pkg.Msg", msg)).
+//
+// If v doesn't marshal into an object, it's wrapped into a "value" field.
+func TypedPayload(typeURL string, v any) slog.Value {
+	return slog.AnyValue(typedPayload{typeURL: typeURL, v: v})
+}
+
+type typedPayload struct {
+	typeURL string
+	v       any
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p typedPayload) MarshalJSON() ([]byte, error) {
+	data, err := tokens.AppendMarshal(nil, p.v)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	buf := make([]byte, 0, len(FieldType)+len(p.typeURL)+len(data)+16)
+	buf = append(buf, '{')
+	buf = tokens.AppendString(buf, FieldType)
+	buf = append(buf, ':')
+	buf = tokens.AppendString(buf, p.typeURL)
+	switch {
+	case len(data) == 0 || data[0] != '{':
+		buf = append(buf, ',')
+		buf = tokens.AppendString(buf, fieldTypedPayloadValue)
+		buf = append(buf, ':')
+		buf = append(buf, data...)
+		buf = append(buf, '}')
+	case bytes.Equal(data, []byte("{}")):
+		buf = append(buf, '}')
+	default:
+		buf = append(buf, ',')
+		buf = append(buf, data[1:]...)
+	}
+	return buf, nil
+}
+
+const fieldTypedPayloadValue = "value"
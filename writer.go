@@ -0,0 +1,114 @@
+package slogdriver
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// fullWriter retries short writes to the underlying writer until the whole
+// buffer has been written or an error occurs. The writes of a single buffer
+// are serialized so that concurrent entries don't get interleaved.
+//
+// If the separator is set, it's written before each buffer in the same write.
+type fullWriter struct {
+	m         sync.Mutex
+	w         io.Writer
+	separator byte
+	buf       []byte
+}
+
+// Write implements io.Writer.
+func (w *fullWriter) Write(data []byte) (n int, err error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.separator != 0 {
+		w.buf = append(append(w.buf[:0], w.separator), data...)
+		n, err = w.write(w.buf)
+		return max(n-1, 0), err
+	}
+	return w.write(data)
+}
+
+func (w *fullWriter) write(data []byte) (n int, err error) {
+	for n < len(data) {
+		var written int
+		written, err = w.w.Write(data[n:])
+		n += written
+		if err != nil {
+			return n, err
+		}
+		if written == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+
+	return n, nil
+}
+
+// Flush flushes the underlying writer if it has a Flush method.
+func (w *fullWriter) Flush() error {
+	f, ok := w.w.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return f.Flush()
+}
+
+// Sync commits the written data of the underlying writer to stable storage
+// if it has a Sync method, such as os.File.
+func (w *fullWriter) Sync() error {
+	s, ok := w.w.(interface{ Sync() error })
+	if !ok {
+		return nil
+	}
+	return s.Sync()
+}
+
+// canSync reports whether the underlying writer has a Sync method.
+func (w *fullWriter) canSync() bool {
+	_, ok := w.w.(interface{ Sync() error })
+	return ok
+}
+
+// syncLoop syncs a writer periodically in the background until stopped.
+type syncLoop struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startSyncLoop(w *fullWriter, interval time.Duration, onError func(error)) *syncLoop {
+	s := &syncLoop{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := w.Sync(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// Stop stops the loop and waits for an ongoing sync to finish. It's safe to
+// call multiple times.
+func (s *syncLoop) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}